@@ -0,0 +1,281 @@
+// Package capture records every mutation to a simulated world into a single
+// append-only file and can replay that file back frame by frame, so a
+// simulation session can be shared or debugged later without access to the
+// live server that produced it.
+package capture
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// magic identifies a capture file; version allows the frame format to
+// change without breaking detection of non-capture files.
+const (
+	magic   uint32 = 0x43495643 // "CIVC"
+	version uint8  = 1
+)
+
+// FrameType distinguishes the kinds of frames a capture file can contain.
+type FrameType uint8
+
+const (
+	// FrameGeneration carries the parameters used to regenerate the initial
+	// world deterministically. It is always the first frame, at tick 0.
+	FrameGeneration FrameType = iota
+	// FrameTick carries the per-parcel state resulting from one simulation tick.
+	FrameTick
+	// FrameEvent carries a single handler-emitted event (see main.Event).
+	FrameEvent
+	// FrameAPICall records a client API request made while capturing.
+	FrameAPICall
+)
+
+// Header describes the world a capture was recorded against. A capture
+// always records a single dimension.
+type Header struct {
+	Dimension string
+	Seed      int64
+	Width     float64
+	Height    float64
+}
+
+// GenerationPayload is the FrameGeneration frame's payload: everything
+// needed to call WorldSimulator.Generate and reproduce the initial world.
+type GenerationPayload struct {
+	Dimension  string  `json:"dimension"`
+	Seed       int64   `json:"seed"`
+	Width      float64 `json:"width"`
+	Height     float64 `json:"height"`
+	NumParcels int     `json:"numParcels"`
+}
+
+// ParcelState is one parcel's mutable state at a point in the capture.
+type ParcelState struct {
+	ParcelID    int        `json:"parcelId"`
+	Elevation   float64    `json:"elevation"`
+	Moisture    float64    `json:"moisture"`
+	Temperature float64    `json:"temperature"`
+	Resources   []Resource `json:"resources"`
+}
+
+// Resource mirrors world.Resource so this package has no dependency on the
+// simulator's domain types.
+type Resource struct {
+	Type       string  `json:"type"`
+	Current    float64 `json:"current"`
+	Maximum    float64 `json:"maximum"`
+	ChangeRate float64 `json:"changeRate"`
+}
+
+// EntityState is one entity's full state at a point in the capture. Unlike
+// parcels, entities are mobile and short-lived, so each tick frame carries
+// every entity currently alive rather than a diff.
+type EntityState struct {
+	ID       int64                  `json:"id"`
+	Type     string                 `json:"type"`
+	X        float64                `json:"x"`
+	Y        float64                `json:"y"`
+	ParcelID int                    `json:"parcelId"`
+	State    map[string]interface{} `json:"state,omitempty"`
+}
+
+// TickPayload is a FrameTick frame's payload.
+type TickPayload struct {
+	Dimension string        `json:"dimension"`
+	DeltaTime float64       `json:"deltaTime"`
+	Parcels   []ParcelState `json:"parcels"`
+	Entities  []EntityState `json:"entities"`
+}
+
+// EventPayload is a FrameEvent frame's payload.
+type EventPayload struct {
+	Dimension string      `json:"dimension"`
+	Type      string      `json:"type"`
+	Payload   interface{} `json:"payload"`
+}
+
+// APICallPayload is a FrameAPICall frame's payload.
+type APICallPayload struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// writeString writes a length-prefixed string, matching the rest of this
+// package's fixed-field binary framing.
+func writeString(w io.Writer, s string) error {
+	if err := binary.Write(w, binary.BigEndian, uint32(len(s))); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, s)
+	return err
+}
+
+// readString reads a string written by writeString.
+func readString(r io.Reader) (string, error) {
+	var length uint32
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return "", err
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Recorder appends frames to a capture file.
+type Recorder struct {
+	f *os.File
+}
+
+// StartRecording creates a new capture file at path and writes its header.
+func StartRecording(path string, header Header) (*Recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("creating capture file: %w", err)
+	}
+
+	if err := binary.Write(f, binary.BigEndian, magic); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := binary.Write(f, binary.BigEndian, version); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := writeString(f, header.Dimension); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := binary.Write(f, binary.BigEndian, header.Seed); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := binary.Write(f, binary.BigEndian, header.Width); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := binary.Write(f, binary.BigEndian, header.Height); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &Recorder{f: f}, nil
+}
+
+// WriteFrame appends one length-prefixed frame to the capture. payload is
+// JSON-encoded before being written.
+func (r *Recorder) WriteFrame(tick uint64, frameType FrameType, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encoding frame payload: %w", err)
+	}
+
+	if err := binary.Write(r.f, binary.BigEndian, tick); err != nil {
+		return err
+	}
+	if err := binary.Write(r.f, binary.BigEndian, uint8(frameType)); err != nil {
+		return err
+	}
+	if err := binary.Write(r.f, binary.BigEndian, uint32(len(data))); err != nil {
+		return err
+	}
+	_, err = r.f.Write(data)
+	return err
+}
+
+// Close flushes and closes the underlying file.
+func (r *Recorder) Close() error {
+	return r.f.Close()
+}
+
+// Player reads frames back out of a capture file in order.
+type Player struct {
+	f      *os.File
+	Header Header
+}
+
+// Open opens a capture file and validates + reads its header. The player is
+// left positioned at the first frame.
+func Open(path string) (*Player, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening capture file: %w", err)
+	}
+
+	var gotMagic uint32
+	var gotVersion uint8
+	var header Header
+
+	if err := binary.Read(f, binary.BigEndian, &gotMagic); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("reading capture magic: %w", err)
+	}
+	if gotMagic != magic {
+		f.Close()
+		return nil, fmt.Errorf("not a capture file (bad magic)")
+	}
+	if err := binary.Read(f, binary.BigEndian, &gotVersion); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("reading capture version: %w", err)
+	}
+	if gotVersion != version {
+		f.Close()
+		return nil, fmt.Errorf("unsupported capture version %d", gotVersion)
+	}
+	dimension, err := readString(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("reading capture dimension: %w", err)
+	}
+	header.Dimension = dimension
+	if err := binary.Read(f, binary.BigEndian, &header.Seed); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := binary.Read(f, binary.BigEndian, &header.Width); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := binary.Read(f, binary.BigEndian, &header.Height); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &Player{f: f, Header: header}, nil
+}
+
+// Next returns the next frame in the capture, or io.EOF once the file is
+// exhausted.
+func (p *Player) Next() (tick uint64, frameType FrameType, payload []byte, err error) {
+	if err := binary.Read(p.f, binary.BigEndian, &tick); err != nil {
+		return 0, 0, nil, err
+	}
+
+	var rawType uint8
+	if err := binary.Read(p.f, binary.BigEndian, &rawType); err != nil {
+		return 0, 0, nil, err
+	}
+
+	var length uint32
+	if err := binary.Read(p.f, binary.BigEndian, &length); err != nil {
+		return 0, 0, nil, err
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(p.f, data); err != nil {
+		return 0, 0, nil, err
+	}
+
+	return tick, FrameType(rawType), data, nil
+}
+
+// Close releases the underlying file.
+func (p *Player) Close() error {
+	return p.f.Close()
+}
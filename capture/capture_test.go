@@ -0,0 +1,154 @@
+package capture
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecorderPlayerRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.cap")
+
+	rec, err := StartRecording(path, Header{
+		Dimension: "overworld",
+		Seed:      7,
+		Width:     100,
+		Height:    200,
+	})
+	if err != nil {
+		t.Fatalf("StartRecording: %v", err)
+	}
+
+	generation := GenerationPayload{Dimension: "overworld", Seed: 7, Width: 100, Height: 200, NumParcels: 50}
+	if err := rec.WriteFrame(0, FrameGeneration, generation); err != nil {
+		t.Fatalf("WriteFrame(FrameGeneration): %v", err)
+	}
+
+	tick := TickPayload{
+		Dimension: "overworld",
+		DeltaTime: 1,
+		Parcels:   []ParcelState{{ParcelID: 1, Elevation: 0.5}},
+		Entities:  []EntityState{{ID: 42, Type: "herd", X: 1, Y: 2, ParcelID: 1}},
+	}
+	if err := rec.WriteFrame(1, FrameTick, tick); err != nil {
+		t.Fatalf("WriteFrame(FrameTick): %v", err)
+	}
+
+	event := EventPayload{Dimension: "overworld", Type: "famine", Payload: map[string]interface{}{"parcelId": 1.0}}
+	if err := rec.WriteFrame(1, FrameEvent, event); err != nil {
+		t.Fatalf("WriteFrame(FrameEvent): %v", err)
+	}
+
+	apiCall := APICallPayload{Method: "POST", Path: "/api/entity/spawn", Body: json.RawMessage(`{"type":"herd"}`)}
+	if err := rec.WriteFrame(2, FrameAPICall, apiCall); err != nil {
+		t.Fatalf("WriteFrame(FrameAPICall): %v", err)
+	}
+
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	player, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer player.Close()
+
+	if player.Header.Dimension != "overworld" || player.Header.Seed != 7 || player.Header.Width != 100 || player.Header.Height != 200 {
+		t.Fatalf("Header = %+v, want {overworld 7 100 200}", player.Header)
+	}
+
+	wantTick := []uint64{0, 1, 1, 2}
+	wantType := []FrameType{FrameGeneration, FrameTick, FrameEvent, FrameAPICall}
+
+	for i := 0; i < 4; i++ {
+		gotTick, gotType, payload, err := player.Next()
+		if err != nil {
+			t.Fatalf("Next() frame %d: %v", i, err)
+		}
+		if gotTick != wantTick[i] || gotType != wantType[i] {
+			t.Fatalf("Next() frame %d = (tick %d, type %d), want (tick %d, type %d)", i, gotTick, gotType, wantTick[i], wantType[i])
+		}
+
+		switch gotType {
+		case FrameGeneration:
+			var got GenerationPayload
+			if err := json.Unmarshal(payload, &got); err != nil {
+				t.Fatalf("unmarshaling generation payload: %v", err)
+			}
+			if got != generation {
+				t.Fatalf("generation payload = %+v, want %+v", got, generation)
+			}
+		case FrameTick:
+			var got TickPayload
+			if err := json.Unmarshal(payload, &got); err != nil {
+				t.Fatalf("unmarshaling tick payload: %v", err)
+			}
+			if len(got.Parcels) != 1 || got.Parcels[0].ParcelID != 1 {
+				t.Fatalf("tick payload parcels = %+v, want one parcel with ID 1", got.Parcels)
+			}
+			if len(got.Entities) != 1 || got.Entities[0].ID != 42 || got.Entities[0].Type != "herd" {
+				t.Fatalf("tick payload entities = %+v, want one herd entity with id 42", got.Entities)
+			}
+		case FrameEvent:
+			var got EventPayload
+			if err := json.Unmarshal(payload, &got); err != nil {
+				t.Fatalf("unmarshaling event payload: %v", err)
+			}
+			if got.Type != "famine" {
+				t.Fatalf("event payload = %+v, want type famine", got)
+			}
+		case FrameAPICall:
+			var got APICallPayload
+			if err := json.Unmarshal(payload, &got); err != nil {
+				t.Fatalf("unmarshaling api call payload: %v", err)
+			}
+			if got.Method != "POST" || got.Path != "/api/entity/spawn" {
+				t.Fatalf("api call payload = %+v, want POST /api/entity/spawn", got)
+			}
+		}
+	}
+
+	if _, _, _, err := player.Next(); err != io.EOF {
+		t.Fatalf("Next() after last frame = %v, want io.EOF", err)
+	}
+}
+
+func TestOpenRejectsBadMagic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "not-a-capture.cap")
+	if err := os.WriteFile(path, []byte("definitely not a capture file"), 0o644); err != nil {
+		t.Fatalf("writing garbage file: %v", err)
+	}
+
+	if _, err := Open(path); err == nil {
+		t.Fatalf("Open(garbage file) succeeded, want error")
+	}
+}
+
+func TestOpenRejectsUnsupportedVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "future-version.cap")
+
+	rec, err := StartRecording(path, Header{Dimension: "overworld", Seed: 1, Width: 10, Height: 10})
+	if err != nil {
+		t.Fatalf("StartRecording: %v", err)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading capture file: %v", err)
+	}
+	// The version byte immediately follows the 4-byte magic number.
+	data[4] = version + 1
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("rewriting capture file: %v", err)
+	}
+
+	if _, err := Open(path); err == nil {
+		t.Fatalf("Open(future version) succeeded, want error")
+	}
+}
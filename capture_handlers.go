@@ -0,0 +1,388 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/wzshiming/civilization/capture"
+	"github.com/wzshiming/civilization/world"
+)
+
+// captureDir is where capture files are written and read from.
+const captureDir = "captures"
+
+// sanitizeCaptureFilename reduces a client-supplied capture name to a bare
+// filename, rejecting anything that would let it escape captureDir (a
+// path separator, "..", or an absolute path) before it's joined onto a
+// filesystem path.
+func sanitizeCaptureFilename(name string) (string, error) {
+	base := filepath.Base(name)
+	if base == "" || base == "." || base == ".." || base != name {
+		return "", fmt.Errorf("invalid capture name %q", name)
+	}
+	return base, nil
+}
+
+// StartCapture begins recording every subsequent tick, handler event, and
+// client API call for one dimension to a new capture file under
+// captureDir. Only one capture can be active at a time.
+func (ws *WorldSimulator) StartCapture(dimension, name string) error {
+	dw := ws.dimension(dimension)
+	m := dw.GetMap()
+	if m == nil {
+		return fmt.Errorf("dimension %q has no world generated yet", dimension)
+	}
+	seed := dw.Seed()
+	numParcels := dw.NumParcels()
+
+	ws.captureMu.Lock()
+	defer ws.captureMu.Unlock()
+
+	if ws.capture != nil {
+		return fmt.Errorf("capture %q already in progress", ws.captureName)
+	}
+
+	if err := os.MkdirAll(captureDir, 0o755); err != nil {
+		return fmt.Errorf("creating capture dir: %w", err)
+	}
+
+	safeName, err := sanitizeCaptureFilename(name)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(captureDir, safeName+".cap")
+	rec, err := capture.StartRecording(path, capture.Header{
+		Dimension: dimension,
+		Seed:      seed,
+		Width:     m.Width,
+		Height:    m.Height,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := rec.WriteFrame(0, capture.FrameGeneration, capture.GenerationPayload{
+		Dimension:  dimension,
+		Seed:       seed,
+		Width:      m.Width,
+		Height:     m.Height,
+		NumParcels: numParcels,
+	}); err != nil {
+		rec.Close()
+		return fmt.Errorf("writing generation frame: %w", err)
+	}
+
+	ws.capture = rec
+	ws.captureName = name
+	ws.captureDimension = dimension
+	log.Printf("Capture %q started for dimension %q: %s", name, dimension, path)
+	return nil
+}
+
+// StopCapture ends the named capture, flushing and closing its file. name
+// must match the in-progress capture.
+func (ws *WorldSimulator) StopCapture(name string) error {
+	ws.captureMu.Lock()
+	defer ws.captureMu.Unlock()
+
+	if ws.capture == nil {
+		return fmt.Errorf("no capture in progress")
+	}
+	if name != "" && name != ws.captureName {
+		return fmt.Errorf("capture %q is not in progress (currently recording %q)", name, ws.captureName)
+	}
+
+	err := ws.capture.Close()
+	log.Printf("Capture %q stopped", ws.captureName)
+	ws.capture = nil
+	ws.captureName = ""
+	ws.captureDimension = ""
+	return err
+}
+
+// recordTick appends the given dimension's tick state and events to the
+// active capture, if any and if it's recording that dimension.
+func (ws *WorldSimulator) recordTick(dimension string, ctx *TickContext) {
+	ws.captureMu.Lock()
+	rec := ws.capture
+	recording := ws.captureDimension
+	ws.captureMu.Unlock()
+	if rec == nil || dimension != recording {
+		return
+	}
+
+	parcels := make([]capture.ParcelState, 0, len(ctx.World.Parcels))
+	for id, p := range ctx.World.Parcels {
+		resources := make([]capture.Resource, len(p.Resources))
+		for i, r := range p.Resources {
+			resources[i] = capture.Resource{
+				Type:       r.Type,
+				Current:    r.Current,
+				Maximum:    r.Maximum,
+				ChangeRate: r.ChangeRate,
+			}
+		}
+		parcels = append(parcels, capture.ParcelState{
+			ParcelID:    id,
+			Elevation:   p.Elevation,
+			Moisture:    p.Moisture,
+			Temperature: p.Temperature,
+			Resources:   resources,
+		})
+	}
+
+	entities := make([]capture.EntityState, 0, len(ctx.World.Entities))
+	for id, e := range ctx.World.Entities {
+		entities = append(entities, capture.EntityState{
+			ID:       id,
+			Type:     e.Type,
+			X:        e.Position.X,
+			Y:        e.Position.Y,
+			ParcelID: e.ParcelID,
+			State:    e.State,
+		})
+	}
+
+	if err := rec.WriteFrame(ctx.Tick, capture.FrameTick, capture.TickPayload{
+		Dimension: dimension,
+		DeltaTime: ctx.DeltaTime,
+		Parcels:   parcels,
+		Entities:  entities,
+	}); err != nil {
+		log.Printf("capture: writing tick frame: %v", err)
+	}
+
+	for _, event := range ctx.Events {
+		if err := rec.WriteFrame(ctx.Tick, capture.FrameEvent, capture.EventPayload{
+			Dimension: dimension,
+			Type:      event.Type,
+			Payload:   event.Payload,
+		}); err != nil {
+			log.Printf("capture: writing event frame: %v", err)
+		}
+	}
+}
+
+// recordAPICall appends a client API request to the active capture, if any.
+func (ws *WorldSimulator) recordAPICall(method, path string, body []byte) {
+	ws.captureMu.Lock()
+	rec := ws.capture
+	recording := ws.captureDimension
+	ws.captureMu.Unlock()
+	if rec == nil {
+		return
+	}
+
+	var tick uint64
+	if recording != "" {
+		tick = ws.dimension(recording).Tick()
+	}
+
+	if err := rec.WriteFrame(tick, capture.FrameAPICall, capture.APICallPayload{
+		Method: method,
+		Path:   path,
+		Body:   json.RawMessage(body),
+	}); err != nil {
+		log.Printf("capture: writing API call frame: %v", err)
+	}
+}
+
+func (ws *WorldSimulator) handleCaptureStart(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Dimension string `json:"dimension"`
+		Name      string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	if req.Dimension == "" {
+		req.Dimension = DefaultDimension
+	}
+	if !validDimensionName(req.Dimension) {
+		http.Error(w, fmt.Sprintf("invalid dimension name %q", req.Dimension), http.StatusBadRequest)
+		return
+	}
+
+	if err := ws.StartCapture(req.Dimension, req.Name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+func (ws *WorldSimulator) handleCaptureStop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+	if err := ws.StopCapture(name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// replayTickInterval is the wall-clock spacing assumed between recorded
+// ticks, matching WorldSimulator.Run's ticker.
+const replayTickInterval = 100 * time.Millisecond
+
+// handleReplay streams a capture file back out over SSE, at the requested
+// speed, as if it were a live simulation. It reconstructs the world
+// independently of the live simulator's state.
+func (ws *WorldSimulator) handleReplay(w http.ResponseWriter, r *http.Request) {
+	file := r.URL.Query().Get("file")
+	if file == "" {
+		http.Error(w, "file is required", http.StatusBadRequest)
+		return
+	}
+
+	speed := 1.0
+	if s := r.URL.Query().Get("speed"); s != "" {
+		if parsed, err := strconv.ParseFloat(s, 64); err == nil && parsed > 0 {
+			speed = parsed
+		}
+	}
+
+	safeFile, err := sanitizeCaptureFilename(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	player, err := capture.Open(filepath.Join(captureDir, safeFile))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	defer player.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	replayWorld := &world.Map{Width: player.Header.Width, Height: player.Header.Height}
+	var lastTick uint64
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		default:
+		}
+
+		tick, frameType, payload, err := player.Next()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			log.Printf("replay: reading frame: %v", err)
+			return
+		}
+
+		if tick > lastTick {
+			time.Sleep(time.Duration(float64(tick-lastTick) * float64(replayTickInterval) / speed))
+			lastTick = tick
+		}
+
+		switch frameType {
+		case capture.FrameGeneration:
+			var gen capture.GenerationPayload
+			if err := json.Unmarshal(payload, &gen); err != nil {
+				log.Printf("replay: decoding generation frame: %v", err)
+				continue
+			}
+			replayWorld = generateWorld(gen.Width, gen.Height, gen.NumParcels, gen.Seed)
+			writeSSEMessage(w, flusher, map[string]interface{}{"type": "world_update", "dimension": gen.Dimension, "world": replayWorld})
+		case capture.FrameTick:
+			var tickPayload capture.TickPayload
+			if err := json.Unmarshal(payload, &tickPayload); err != nil {
+				log.Printf("replay: decoding tick frame: %v", err)
+				continue
+			}
+			for _, ps := range tickPayload.Parcels {
+				p, ok := replayWorld.Parcels[ps.ParcelID]
+				if !ok {
+					continue
+				}
+				p.Elevation = ps.Elevation
+				p.Moisture = ps.Moisture
+				p.Temperature = ps.Temperature
+				p.Resources = make([]world.Resource, len(ps.Resources))
+				for i, r := range ps.Resources {
+					p.Resources[i] = world.Resource{
+						Type:       r.Type,
+						Current:    r.Current,
+						Maximum:    r.Maximum,
+						ChangeRate: r.ChangeRate,
+					}
+				}
+			}
+			replayWorld.Entities = make(map[int64]*world.Entity, len(tickPayload.Entities))
+			for _, es := range tickPayload.Entities {
+				replayWorld.Entities[es.ID] = &world.Entity{
+					ID:       es.ID,
+					Type:     es.Type,
+					Position: world.Point{X: es.X, Y: es.Y},
+					ParcelID: es.ParcelID,
+					State:    es.State,
+				}
+			}
+			replayWorld.LastUpdate = time.Now().UnixMilli()
+			writeSSEMessage(w, flusher, map[string]interface{}{"type": "world_update", "dimension": tickPayload.Dimension, "world": replayWorld})
+		case capture.FrameEvent:
+			var event capture.EventPayload
+			if err := json.Unmarshal(payload, &event); err != nil {
+				log.Printf("replay: decoding event frame: %v", err)
+				continue
+			}
+			writeSSEMessage(w, flusher, map[string]interface{}{"type": event.Type, "dimension": event.Dimension, "payload": event.Payload})
+		case capture.FrameAPICall:
+			var call capture.APICallPayload
+			if err := json.Unmarshal(payload, &call); err != nil {
+				log.Printf("replay: decoding API call frame: %v", err)
+				continue
+			}
+			writeSSEMessage(w, flusher, map[string]interface{}{"type": "api_call", "method": call.Method, "path": call.Path})
+		}
+	}
+}
+
+func writeSSEMessage(w http.ResponseWriter, flusher http.Flusher, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("replay: marshaling SSE message: %v", err)
+		return
+	}
+	fmt.Fprintf(w, "data: %s\n\n", data)
+	flusher.Flush()
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wzshiming/civilization/capture"
+	"github.com/wzshiming/civilization/world"
+)
+
+// TestRecordTickCapturesEntities checks that recordTick writes the
+// dimension's entities into the tick frame, and that handleReplay's
+// FrameTick handling reconstructs them on the other end, so a capture of a
+// world with herds/caravans replays with those entities present rather than
+// silently dropping them.
+func TestRecordTickCapturesEntities(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("os.Getwd: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("os.Chdir: %v", err)
+	}
+	defer os.Chdir(wd)
+
+	ws := newTestSimulator(t)
+	dw := ws.dimension("overworld")
+	dw.restore(1, &world.Map{
+		Width:  10,
+		Height: 10,
+		Parcels: map[int]*world.Parcel{
+			1: {ID: 1},
+		},
+		Entities: map[int64]*world.Entity{
+			42: {ID: 42, Type: "herd", Position: world.Point{X: 1, Y: 2}, ParcelID: 1, State: map[string]interface{}{"count": 3.0}},
+		},
+	})
+
+	if err := ws.StartCapture("overworld", "with-entities"); err != nil {
+		t.Fatalf("StartCapture: %v", err)
+	}
+
+	ws.recordTick("overworld", &TickContext{
+		World:     dw.GetMap(),
+		Dimension: "overworld",
+		DeltaTime: 1,
+		Tick:      1,
+	})
+
+	if err := ws.StopCapture("with-entities"); err != nil {
+		t.Fatalf("StopCapture: %v", err)
+	}
+
+	player, err := capture.Open(filepath.Join(captureDir, "with-entities.cap"))
+	if err != nil {
+		t.Fatalf("capture.Open: %v", err)
+	}
+	defer player.Close()
+
+	var sawTick bool
+	for {
+		_, frameType, payload, err := player.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("player.Next: %v", err)
+		}
+		if frameType != capture.FrameTick {
+			continue
+		}
+		sawTick = true
+		var tp capture.TickPayload
+		if err := json.Unmarshal(payload, &tp); err != nil {
+			t.Fatalf("unmarshaling tick payload: %v", err)
+		}
+		if len(tp.Entities) != 1 || tp.Entities[0].ID != 42 || tp.Entities[0].Type != "herd" {
+			t.Fatalf("tick payload entities = %+v, want one herd entity with id 42", tp.Entities)
+		}
+	}
+	if !sawTick {
+		t.Fatalf("capture file had no tick frame")
+	}
+}
+
+func TestSanitizeCaptureFilename(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "plain name", input: "my-world", want: "my-world"},
+		{name: "with extension", input: "my-world.cap", want: "my-world.cap"},
+		{name: "empty", input: "", wantErr: true},
+		{name: "dot", input: ".", wantErr: true},
+		{name: "parent traversal", input: "..", wantErr: true},
+		{name: "relative traversal", input: "../../../../tmp/pwned", wantErr: true},
+		{name: "embedded separator", input: "sub/dir", wantErr: true},
+		{name: "absolute path", input: "/etc/passwd", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sanitizeCaptureFilename(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("sanitizeCaptureFilename(%q) = %q, want error", tt.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("sanitizeCaptureFilename(%q) returned unexpected error: %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Fatalf("sanitizeCaptureFilename(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
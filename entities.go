@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"sync/atomic"
+
+	"github.com/wzshiming/civilization/world"
+)
+
+// nextEntityID hands out globally unique entity IDs across every dimension,
+// so an ID alone is enough to identify an entity without also knowing its
+// dimension.
+var nextEntityID int64
+
+func newEntityID() int64 {
+	return atomic.AddInt64(&nextEntityID, 1)
+}
+
+// seedNextEntityID advances the global entity ID counter past id, if id
+// hasn't already been passed. Resume calls this for every entity it loads
+// from the store, so a freshly spawned entity after a restart can never
+// reuse an ID a resumed entity is still holding.
+func seedNextEntityID(id int64) {
+	for {
+		cur := atomic.LoadInt64(&nextEntityID)
+		if id <= cur {
+			return
+		}
+		if atomic.CompareAndSwapInt64(&nextEntityID, cur, id) {
+			return
+		}
+	}
+}
+
+// EntityBehavior defines how one kind of entity acts on its tick. Behaviors
+// are looked up by Entity.Type, mirroring how SimulationHandler.Name keys
+// the handler pipeline.
+type EntityBehavior interface {
+	Tick(ctx *TickContext, e *world.Entity) error
+}
+
+// entityBehaviors maps an Entity's Type to the behavior that drives it. An
+// entity whose Type isn't registered here is left untouched by EntityHandler.
+var entityBehaviors = map[string]EntityBehavior{
+	"herd":    &HerdBehavior{},
+	"caravan": &CaravanBehavior{},
+}
+
+// EntityHandler runs each entity's registered behavior once per tick.
+type EntityHandler struct{}
+
+func (h *EntityHandler) Name() string { return "entities" }
+
+func (h *EntityHandler) OnTick(ctx *TickContext) error {
+	for _, e := range ctx.World.Entities {
+		behavior, ok := entityBehaviors[e.Type]
+		if !ok {
+			continue
+		}
+		if err := behavior.Tick(ctx, e); err != nil {
+			log.Printf("entity %d (%s): behavior error: %v", e.ID, e.Type, err)
+		}
+	}
+	return nil
+}
+
+// herdMoveChance is the probability per second that a wandering herd moves
+// to a neighboring grassland parcel, rather than grazing in place.
+const herdMoveChance = 0.05
+
+// HerdBehavior wanders an entity randomly between neighboring grassland
+// parcels.
+type HerdBehavior struct{}
+
+func (b *HerdBehavior) Tick(ctx *TickContext, e *world.Entity) error {
+	if rand.Float64() > herdMoveChance*ctx.DeltaTime {
+		return nil
+	}
+
+	parcel, ok := ctx.World.Parcels[e.ParcelID]
+	if !ok {
+		return fmt.Errorf("herd %d: parcel %d not found", e.ID, e.ParcelID)
+	}
+
+	var candidates []int
+	for _, n := range parcel.Neighbors {
+		if neighbor, ok := ctx.World.Parcels[n]; ok && neighbor.Terrain == "grassland" {
+			candidates = append(candidates, n)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	dest := candidates[rand.Intn(len(candidates))]
+	e.ParcelID = dest
+	e.Position = ctx.World.Parcels[dest].Center
+	ctx.Emit("entity_update", e)
+	return nil
+}
+
+// caravanMoveChance is the probability per second that a caravan advances to
+// the next stop on its route.
+const caravanMoveChance = 0.2
+
+// caravanTradeAmount is how much fertile_soil a caravan moves from the stop
+// it's leaving to the stop it arrives at, each time it completes a leg.
+const caravanTradeAmount = 2.0
+
+// CaravanBehavior shuttles an entity back and forth along a fixed route of
+// parcel IDs (e.State["route"]), moving fertile_soil from each stop it
+// leaves to the one it arrives at to model trade between settlements.
+type CaravanBehavior struct{}
+
+func (b *CaravanBehavior) Tick(ctx *TickContext, e *world.Entity) error {
+	route, ok := intSliceState(e.State["route"])
+	if !ok || len(route) < 2 {
+		return nil
+	}
+
+	if rand.Float64() > caravanMoveChance*ctx.DeltaTime {
+		return nil
+	}
+
+	index, _ := intState(e.State["routeIndex"])
+	if index < 0 || index >= len(route) {
+		index = 0
+	}
+	forward, hasForward := e.State["forward"].(bool)
+	if !hasForward {
+		forward = true
+	}
+
+	from := route[index]
+	if forward {
+		index++
+	} else {
+		index--
+	}
+	if index >= len(route) {
+		index = len(route) - 1
+		forward = false
+	}
+	if index < 0 {
+		index = 0
+		forward = true
+	}
+	to := route[index]
+
+	if fromParcel, ok := ctx.World.Parcels[from]; ok {
+		if toParcel, ok := ctx.World.Parcels[to]; ok {
+			if source := findResource(fromParcel, "fertile_soil"); source != nil {
+				traded := clamp(caravanTradeAmount, 0, source.Current)
+				source.Current -= traded
+				if dest := findResource(toParcel, "fertile_soil"); dest != nil {
+					dest.Current = clamp(dest.Current+traded, 0, dest.Maximum)
+				}
+			}
+			e.ParcelID = to
+			e.Position = toParcel.Center
+		}
+	}
+
+	e.State["routeIndex"] = index
+	e.State["forward"] = forward
+	ctx.Emit("entity_update", e)
+	return nil
+}
+
+// intState coerces an Entity.State value into an int, the same way
+// intSliceState does for a slice: a value set directly in Go arrives as
+// int, but one that round-tripped through JSON (e.g. persisted and
+// resumed) arrives as float64.
+func intState(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case float64:
+		return int(n), true
+	default:
+		return 0, false
+	}
+}
+
+// intSliceState coerces an Entity.State value into a []int. State is
+// map[string]interface{}, so a route set directly in Go arrives as []int,
+// but one that round-tripped through JSON (e.g. persisted and resumed)
+// arrives as []interface{} of float64.
+func intSliceState(v interface{}) ([]int, bool) {
+	switch vv := v.(type) {
+	case []int:
+		return vv, true
+	case []interface{}:
+		out := make([]int, 0, len(vv))
+		for _, item := range vv {
+			switch n := item.(type) {
+			case float64:
+				out = append(out, int(n))
+			case int:
+				out = append(out, n)
+			default:
+				return nil, false
+			}
+		}
+		return out, true
+	default:
+		return nil, false
+	}
+}
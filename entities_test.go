@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/wzshiming/civilization/world"
+)
+
+// TestCaravanBehaviorResumesRouteIndexAfterJSON reproduces resuming a
+// caravan from the persistent store: its State round-trips through JSON,
+// so routeIndex comes back as float64 rather than int.
+func TestCaravanBehaviorResumesRouteIndexAfterJSON(t *testing.T) {
+	e := &world.Entity{
+		ID:       1,
+		Type:     "caravan",
+		ParcelID: 10,
+		State: map[string]interface{}{
+			"route":      []int{10, 20, 30},
+			"routeIndex": 1,
+			"forward":    true,
+		},
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		t.Fatalf("marshaling entity: %v", err)
+	}
+	var resumed world.Entity
+	if err := json.Unmarshal(data, &resumed); err != nil {
+		t.Fatalf("unmarshaling entity: %v", err)
+	}
+
+	parcels := map[int]*world.Parcel{
+		10: {ID: 10, Center: world.Point{X: 0, Y: 0}},
+		20: {ID: 20, Center: world.Point{X: 1, Y: 1}},
+		30: {ID: 30, Center: world.Point{X: 2, Y: 2}},
+	}
+	ctx := &TickContext{World: &world.Map{Parcels: parcels}, DeltaTime: 1e9}
+
+	if err := (&CaravanBehavior{}).Tick(ctx, &resumed); err != nil {
+		t.Fatalf("Tick: %v", err)
+	}
+
+	index, ok := intState(resumed.State["routeIndex"])
+	if !ok {
+		t.Fatalf("routeIndex missing or wrong type after tick: %#v", resumed.State["routeIndex"])
+	}
+	if index != 2 {
+		t.Fatalf("routeIndex = %d, want 2 (advanced from resumed index 1, not reset to 0)", index)
+	}
+	if resumed.ParcelID != 30 {
+		t.Fatalf("ParcelID = %d, want 30", resumed.ParcelID)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/wzshiming/civilization/world"
+)
+
+// handleEntities dispatches every /api/entities/... request: spawning,
+// looking up a single entity by ID, and listing entities on a parcel.
+func (ws *WorldSimulator) handleEntities(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/api/entities/spawn":
+		ws.handleEntitySpawn(w, r)
+	case "/api/entities/list":
+		ws.handleEntityList(w, r)
+	default:
+		ws.handleEntityGet(w, r)
+	}
+}
+
+func (ws *WorldSimulator) handleEntitySpawn(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ws.recordAPICall(r.Method, r.URL.Path, body)
+
+	var req struct {
+		Dimension string                 `json:"dimension"`
+		Type      string                 `json:"type"`
+		ParcelID  int                    `json:"parcelId"`
+		State     map[string]interface{} `json:"state"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Type == "" {
+		http.Error(w, "type is required", http.StatusBadRequest)
+		return
+	}
+	if req.Dimension == "" {
+		req.Dimension = DefaultDimension
+	}
+	if !validDimensionName(req.Dimension) {
+		http.Error(w, fmt.Sprintf("invalid dimension name %q", req.Dimension), http.StatusBadRequest)
+		return
+	}
+
+	dw := ws.dimension(req.Dimension)
+	m := dw.GetMap()
+	if m == nil {
+		http.Error(w, fmt.Sprintf("dimension %q has no world generated yet", req.Dimension), http.StatusBadRequest)
+		return
+	}
+	parcel, ok := m.Parcels[req.ParcelID]
+	if !ok {
+		http.Error(w, fmt.Sprintf("parcel %d not found in dimension %q", req.ParcelID, req.Dimension), http.StatusBadRequest)
+		return
+	}
+
+	entity := &world.Entity{
+		ID:       newEntityID(),
+		Type:     req.Type,
+		Position: parcel.Center,
+		ParcelID: req.ParcelID,
+		State:    req.State,
+	}
+	dw.AddEntity(entity)
+
+	if ws.store != nil {
+		if err := ws.store.SaveEntity(req.Dimension, entity.ID, entity); err != nil {
+			log.Printf("worldstore: saving entity %d in dimension %q: %v", entity.ID, req.Dimension, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entity)
+}
+
+func (ws *WorldSimulator) handleEntityGet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	idStr := strings.TrimPrefix(r.URL.Path, "/api/entities/")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		http.Error(w, "invalid entity id", http.StatusBadRequest)
+		return
+	}
+
+	dimensions := []string{r.URL.Query().Get("dimension")}
+	if dimensions[0] == "" {
+		dimensions = ws.dimensionNames()
+	}
+
+	for _, name := range dimensions {
+		dw := ws.lookupDimension(name)
+		if dw == nil {
+			continue
+		}
+		if entity, ok := dw.GetEntity(id); ok {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(entity)
+			return
+		}
+	}
+
+	http.Error(w, "entity not found", http.StatusNotFound)
+}
+
+func (ws *WorldSimulator) handleEntityList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parcelID, err := strconv.Atoi(r.URL.Query().Get("parcel"))
+	if err != nil {
+		http.Error(w, "parcel is required", http.StatusBadRequest)
+		return
+	}
+
+	dimension := r.URL.Query().Get("dimension")
+	if dimension == "" {
+		dimension = DefaultDimension
+	}
+	if !validDimensionName(dimension) {
+		http.Error(w, fmt.Sprintf("invalid dimension name %q", dimension), http.StatusBadRequest)
+		return
+	}
+
+	var entities []*world.Entity
+	if dw := ws.lookupDimension(dimension); dw != nil {
+		entities = dw.EntitiesOnParcel(parcelID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entities)
+}
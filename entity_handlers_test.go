@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+// TestHandleEntityListDoesNotCreateDimension checks that listing entities
+// on an unknown dimension returns an empty list rather than registering a
+// new, permanently empty World as a side effect of a read-only request.
+func TestHandleEntityListDoesNotCreateDimension(t *testing.T) {
+	ws := newTestSimulator(t)
+
+	req := httptest.NewRequest("GET", "/api/entities/list?parcel=0&dimension=nowhere", nil)
+	rec := httptest.NewRecorder()
+	ws.handleEntityList(rec, req)
+
+	if rec.Code != 200 {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if body := rec.Body.String(); body != "null\n" && body != "[]\n" {
+		t.Fatalf("body = %q, want an empty list", body)
+	}
+	if ws.lookupDimension("nowhere") != nil {
+		t.Fatalf("dimension %q was created by a read-only list request", "nowhere")
+	}
+}
+
+// TestHandleEntityListRejectsInvalidDimensionName checks that
+// handleEntityList enforces the same dimension name validation every other
+// dimension-taking handler does.
+func TestHandleEntityListRejectsInvalidDimensionName(t *testing.T) {
+	ws := newTestSimulator(t)
+
+	req := httptest.NewRequest("GET", "/api/entities/list?parcel=0&dimension=bad%23name", nil)
+	rec := httptest.NewRecorder()
+	ws.handleEntityList(rec, req)
+
+	if rec.Code != 400 {
+		t.Fatalf("status = %d, want 400 for an invalid dimension name", rec.Code)
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"math"
+
+	"github.com/wzshiming/civilization/world"
+)
+
+// TickContext carries the state a SimulationHandler needs for a single tick,
+// plus an outlet for typed events the handler wants broadcast over SSE.
+type TickContext struct {
+	World     *world.Map
+	Dimension string
+	DeltaTime float64
+	Tick      uint64
+	Events    []Event
+
+	// MutateOtherParcel safely reads and mutates a single parcel in another
+	// dimension, for handlers that need to reach across a portal. It holds
+	// the target dimension's own lock for the full read-modify-write, so it
+	// can't race with that dimension's own tick or API handlers the way a
+	// bare *world.Map pointer would. It reports whether the dimension and
+	// parcel were found. It is nil outside of WorldSimulator.simulateDimension
+	// (e.g. during capture replay), so callers must check before using it.
+	MutateOtherParcel func(dimension string, parcelID int, fn func(p *world.Parcel)) bool
+}
+
+// Emit queues a typed event to be broadcast to SSE clients once the tick's
+// handlers have all run. Payload is marshaled as JSON, so it should be a
+// plain value or a type with json tags.
+func (tc *TickContext) Emit(eventType string, payload interface{}) {
+	tc.Events = append(tc.Events, Event{Type: eventType, Payload: payload})
+}
+
+// Event is a discrete, named happening emitted by a handler during a tick
+// (e.g. "fire_started", "settlement_founded", "drought").
+type Event struct {
+	Type    string      `json:"type"`
+	Payload interface{} `json:"payload"`
+}
+
+// SimulationHandler is one subsystem in the tick pipeline. Handlers run in
+// registration order and are free to mutate the world in place.
+type SimulationHandler interface {
+	Name() string
+	OnTick(ctx *TickContext) error
+}
+
+// ParcelChangeHandler is an optional hook a SimulationHandler can implement
+// to be notified when another handler changes a parcel during the same
+// tick, without having to re-scan the whole world itself.
+type ParcelChangeHandler interface {
+	OnParcelChange(old, new *world.Parcel)
+}
+
+// ResourceRegrowthHandler grows or depletes each parcel's resources toward
+// their natural rate. This is the original behavior of WorldSimulator.Simulate,
+// lifted out into its own handler.
+type ResourceRegrowthHandler struct{}
+
+func (h *ResourceRegrowthHandler) Name() string { return "resource_regrowth" }
+
+func (h *ResourceRegrowthHandler) OnTick(ctx *TickContext) error {
+	for _, parcel := range ctx.World.Parcels {
+		for i := range parcel.Resources {
+			resource := &parcel.Resources[i]
+			resource.Current += resource.ChangeRate * ctx.DeltaTime
+			if resource.Current > resource.Maximum {
+				resource.Current = resource.Maximum
+			}
+			if resource.Current < 0 {
+				resource.Current = 0
+			}
+		}
+	}
+	return nil
+}
+
+// hydrologyFlowRate controls how quickly moisture equalizes between
+// neighboring parcels along the elevation gradient.
+const hydrologyFlowRate = 0.05
+
+// droughtMoistureThreshold is how low a parcel's moisture has to fall before
+// HydrologyHandler reports a "drought" event for it.
+const droughtMoistureThreshold = -0.9
+
+// HydrologyHandler redistributes moisture between neighboring parcels: wetter,
+// lower ground pulls moisture from drier, higher neighbors, approximating
+// surface water flow downhill.
+type HydrologyHandler struct{}
+
+func (h *HydrologyHandler) Name() string { return "hydrology" }
+
+func (h *HydrologyHandler) OnTick(ctx *TickContext) error {
+	deltas := make(map[int]float64, len(ctx.World.Parcels))
+
+	for id, parcel := range ctx.World.Parcels {
+		for _, neighborID := range parcel.Neighbors {
+			neighbor, ok := ctx.World.Parcels[neighborID]
+			if !ok || neighbor.Elevation >= parcel.Elevation {
+				continue
+			}
+			// Moisture flows from the higher, wetter parcel to the lower one.
+			flow := (parcel.Moisture - neighbor.Moisture) * hydrologyFlowRate * ctx.DeltaTime
+			if flow <= 0 {
+				continue
+			}
+			deltas[id] -= flow
+			deltas[neighborID] += flow
+		}
+	}
+
+	for id, delta := range deltas {
+		parcel := ctx.World.Parcels[id]
+		parcel.Moisture = clamp(parcel.Moisture+delta, -1, 1)
+		if parcel.Moisture <= droughtMoistureThreshold {
+			ctx.Emit("drought", map[string]interface{}{"parcelId": id, "moisture": parcel.Moisture})
+		}
+	}
+
+	return nil
+}
+
+// temperatureDiffusionRate controls how quickly a parcel's temperature
+// equalizes with its neighbors.
+const temperatureDiffusionRate = 0.02
+
+// TemperatureDiffusionHandler smooths temperature across neighboring parcels,
+// simulating heat exchange between adjacent terrain.
+type TemperatureDiffusionHandler struct{}
+
+func (h *TemperatureDiffusionHandler) Name() string { return "temperature_diffusion" }
+
+func (h *TemperatureDiffusionHandler) OnTick(ctx *TickContext) error {
+	next := make(map[int]float64, len(ctx.World.Parcels))
+
+	for id, parcel := range ctx.World.Parcels {
+		if len(parcel.Neighbors) == 0 {
+			next[id] = parcel.Temperature
+			continue
+		}
+		var sum float64
+		for _, neighborID := range parcel.Neighbors {
+			if neighbor, ok := ctx.World.Parcels[neighborID]; ok {
+				sum += neighbor.Temperature
+			}
+		}
+		avg := sum / float64(len(parcel.Neighbors))
+		next[id] = parcel.Temperature + (avg-parcel.Temperature)*temperatureDiffusionRate*ctx.DeltaTime
+	}
+
+	for id, temperature := range next {
+		ctx.World.Parcels[id].Temperature = clamp(temperature, -1, 1)
+	}
+
+	return nil
+}
+
+// settlementFertileSoilThreshold is how much fertile_soil a grassland parcel
+// needs accumulated before PopulationHandler founds a settlement on it.
+const settlementFertileSoilThreshold = 60.0
+
+// settlementConsumptionRate is how much fertile_soil an established
+// settlement consumes per second.
+const settlementConsumptionRate = 0.4
+
+// PopulationHandler grows settlements on fertile parcels and has them consume
+// resources over time. Settlement state is tracked internally by parcel ID;
+// OnTick is only ever called sequentially from WorldSimulator.Simulate, so no
+// additional locking is needed here.
+type PopulationHandler struct {
+	settlements map[int]bool
+}
+
+func (h *PopulationHandler) Name() string { return "population" }
+
+func (h *PopulationHandler) OnTick(ctx *TickContext) error {
+	if h.settlements == nil {
+		h.settlements = make(map[int]bool)
+	}
+
+	for id, parcel := range ctx.World.Parcels {
+		if parcel.Terrain != "grassland" {
+			continue
+		}
+
+		soil := findResource(parcel, "fertile_soil")
+		if soil == nil {
+			continue
+		}
+
+		if !h.settlements[id] {
+			if soil.Current >= settlementFertileSoilThreshold {
+				h.settlements[id] = true
+				ctx.Emit("settlement_founded", map[string]interface{}{"parcelId": id})
+			}
+			continue
+		}
+
+		soil.Current -= settlementConsumptionRate * ctx.DeltaTime
+		if soil.Current < 0 {
+			soil.Current = 0
+		}
+	}
+
+	return nil
+}
+
+// portalElevationCoupling controls how strongly a portal parcel's elevation
+// pulls its linked parcel's elevation in another dimension toward it each
+// tick, approximating structural effects like mining subsidence propagating
+// from an underground dimension up to the surface.
+const portalElevationCoupling = 0.01
+
+// PortalHandler links parcels across dimensions via world.Parcel.Portal and
+// couples their elevation, so changes on one side (e.g. underground mining)
+// are felt on the other (e.g. surface subsidence).
+type PortalHandler struct{}
+
+func (h *PortalHandler) Name() string { return "portal" }
+
+func (h *PortalHandler) OnTick(ctx *TickContext) error {
+	if ctx.MutateOtherParcel == nil {
+		return nil
+	}
+
+	for id, parcel := range ctx.World.Parcels {
+		if parcel.Portal == nil {
+			continue
+		}
+
+		var delta float64
+		found := ctx.MutateOtherParcel(parcel.Portal.Dimension, parcel.Portal.ParcelID, func(linked *world.Parcel) {
+			delta = (parcel.Elevation - linked.Elevation) * portalElevationCoupling * ctx.DeltaTime
+			if delta == 0 {
+				return
+			}
+			linked.Elevation = clamp(linked.Elevation+delta, -1, 1)
+		})
+		if !found || delta == 0 {
+			continue
+		}
+
+		ctx.Emit("portal_transfer", map[string]interface{}{
+			"parcelId":        id,
+			"dimension":       ctx.Dimension,
+			"linkedParcelId":  parcel.Portal.ParcelID,
+			"linkedDimension": parcel.Portal.Dimension,
+			"elevationDelta":  delta,
+		})
+	}
+
+	return nil
+}
+
+func findResource(p *world.Parcel, resourceType string) *world.Resource {
+	for i := range p.Resources {
+		if p.Resources[i].Type == resourceType {
+			return &p.Resources[i]
+		}
+	}
+	return nil
+}
+
+func clamp(v, min, max float64) float64 {
+	return math.Max(min, math.Min(max, v))
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/wzshiming/civilization/world"
+)
+
+func TestResourceRegrowthHandlerClampsToBounds(t *testing.T) {
+	parcel := &world.Parcel{
+		ID: 1,
+		Resources: []world.Resource{
+			{Type: "wood", Current: 95, Maximum: 100, ChangeRate: 50},
+			{Type: "ore", Current: 5, Maximum: 100, ChangeRate: -50},
+		},
+	}
+	ctx := &TickContext{World: &world.Map{Parcels: map[int]*world.Parcel{1: parcel}}, DeltaTime: 1}
+
+	if err := (&ResourceRegrowthHandler{}).OnTick(ctx); err != nil {
+		t.Fatalf("OnTick: %v", err)
+	}
+
+	if got := parcel.Resources[0].Current; got != 100 {
+		t.Errorf("wood.Current = %v, want clamped to 100", got)
+	}
+	if got := parcel.Resources[1].Current; got != 0 {
+		t.Errorf("ore.Current = %v, want clamped to 0", got)
+	}
+}
+
+func TestHydrologyHandlerFlowsDownhillAndReportsDrought(t *testing.T) {
+	high := &world.Parcel{ID: 1, Elevation: 1, Moisture: 1, Neighbors: []int{2}}
+	low := &world.Parcel{ID: 2, Elevation: 0, Moisture: -1, Neighbors: []int{1}}
+	ctx := &TickContext{
+		World:     &world.Map{Parcels: map[int]*world.Parcel{1: high, 2: low}},
+		DeltaTime: 0.01,
+	}
+
+	if err := (&HydrologyHandler{}).OnTick(ctx); err != nil {
+		t.Fatalf("OnTick: %v", err)
+	}
+
+	if high.Moisture >= 1 {
+		t.Errorf("high.Moisture = %v, want it to have lost moisture to the lower parcel", high.Moisture)
+	}
+	if low.Moisture <= -1 {
+		t.Errorf("low.Moisture = %v, want it to have gained moisture from the higher parcel", low.Moisture)
+	}
+
+	var sawDrought bool
+	for _, e := range ctx.Events {
+		if e.Type == "drought" {
+			sawDrought = true
+		}
+	}
+	if !sawDrought {
+		t.Errorf("no drought event emitted for a parcel below the threshold")
+	}
+}
+
+func TestWorldAddRemoveHandler(t *testing.T) {
+	w := &World{name: "test"}
+	a := &ResourceRegrowthHandler{}
+	b := &HydrologyHandler{}
+
+	w.AddHandler(a)
+	w.AddHandler(b)
+	if len(w.handlers) != 2 || w.handlers[0] != a || w.handlers[1] != b {
+		t.Fatalf("handlers after AddHandler = %v, want [a, b] in registration order", w.handlers)
+	}
+
+	w.RemoveHandler(a.Name())
+	if len(w.handlers) != 1 || w.handlers[0] != b {
+		t.Fatalf("handlers after RemoveHandler(%q) = %v, want [b]", a.Name(), w.handlers)
+	}
+
+	w.RemoveHandler("does-not-exist")
+	if len(w.handlers) != 1 {
+		t.Fatalf("RemoveHandler with an unknown name changed the pipeline: %v", w.handlers)
+	}
+}
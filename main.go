@@ -1,91 +1,243 @@
 package main
 
 import (
+	"compress/gzip"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"math"
 	"math/rand"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/aquilax/go-perlin"
+
+	"github.com/wzshiming/civilization/capture"
+	"github.com/wzshiming/civilization/world"
+	"github.com/wzshiming/civilization/worldstore"
 )
 
-// Point represents a 2D coordinate
-type Point struct {
-	X float64 `json:"x"`
-	Y float64 `json:"y"`
+// ClientState is one connected SSE subscriber. An empty dimension means it
+// wants updates for every dimension; otherwise it's filtered to just one.
+// lastKeyframeID and behind track its place in the delta/keyframe protocol
+// (see broadcastDelta) per dimension, since an unfiltered client follows
+// every dimension independently: falling behind on one dimension's deltas
+// must not affect any other dimension it's subscribed to. Both are guarded
+// by mu rather than clientsMu, since handleSSE's initial per-dimension
+// resync writes to a client already visible to concurrent fanOut calls.
+type ClientState struct {
+	ch        chan string
+	dimension string
+
+	mu             sync.Mutex
+	lastKeyframeID map[string]uint64
+	behind         map[string]bool
 }
 
-// Resource represents a resource with dynamic properties
-type Resource struct {
-	Type       string  `json:"type"`
-	Current    float64 `json:"current"`
-	Maximum    float64 `json:"maximum"`
-	ChangeRate float64 `json:"changeRate"`
+// setLastKeyframeID records the last keyframe id a client is known to have
+// received for a dimension.
+func (c *ClientState) setLastKeyframeID(dimension string, id uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lastKeyframeID == nil {
+		c.lastKeyframeID = make(map[string]uint64)
+	}
+	c.lastKeyframeID[dimension] = id
 }
 
-// Parcel represents a map region
-type Parcel struct {
-	ID          int        `json:"id"`
-	Vertices    []Point    `json:"vertices"`
-	Center      Point      `json:"center"`
-	Terrain     string     `json:"terrain"`
-	Resources   []Resource `json:"resources"`
-	Neighbors   []int      `json:"neighbors"`
-	Elevation   float64    `json:"elevation"`
-	Moisture    float64    `json:"moisture"`
-	Temperature float64    `json:"temperature"`
+// isBehind reports whether a client is behind on a dimension's deltas,
+// i.e. missed one and is waiting for that dimension's next keyframe.
+func (c *ClientState) isBehind(dimension string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.behind[dimension]
 }
 
-// WorldMap represents the complete world state
-type WorldMap struct {
-	Parcels    map[int]*Parcel `json:"parcels"`
-	Width      float64         `json:"width"`
-	Height     float64         `json:"height"`
-	LastUpdate int64           `json:"lastUpdate"`
+// setBehind marks whether a client is behind on a dimension's deltas.
+func (c *ClientState) setBehind(dimension string, behind bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.behind == nil {
+		c.behind = make(map[string]bool)
+	}
+	c.behind[dimension] = behind
 }
 
-// WorldSimulator manages the world state and simulation
+// WorldSimulator manages a keyed set of dimensions (worlds), each with its
+// own map and simulation pipeline, plus the persistence and capture
+// subsystems shared across all of them.
 type WorldSimulator struct {
-	mu              sync.RWMutex
-	world           *WorldMap
-	isSimulating    bool
-	simulationSpeed float64
-	seed            int64
-	numParcels      int
-	clients         map[chan string]bool
-	clientsMu       sync.Mutex
+	mu     sync.RWMutex
+	worlds map[string]*World
+
+	store *worldstore.Store
+
+	captureMu        sync.Mutex
+	capture          *capture.Recorder
+	captureName      string
+	captureDimension string
+
+	clients   map[chan string]*ClientState
+	clientsMu sync.Mutex
+
+	broadcastMu    sync.Mutex
+	broadcastState map[string]*dimensionBroadcastState
 }
 
-func NewWorldSimulator() *WorldSimulator {
-	return &WorldSimulator{
-		clients:         make(map[chan string]bool),
-		simulationSpeed: 1.0,
-		numParcels:      500,
+// NewWorldSimulator creates a simulator. If storePath is non-empty, world
+// state is persisted to (and can be resumed from) a LevelDB database at that
+// path; pass "" to run in memory only.
+func NewWorldSimulator(storePath string) (*WorldSimulator, error) {
+	ws := &WorldSimulator{
+		worlds:         make(map[string]*World),
+		clients:        make(map[chan string]*ClientState),
+		broadcastState: make(map[string]*dimensionBroadcastState),
+	}
+
+	if storePath != "" {
+		store, err := worldstore.Open(storePath)
+		if err != nil {
+			return nil, fmt.Errorf("opening world store: %w", err)
+		}
+		ws.store = store
 	}
+
+	return ws, nil
 }
 
-// Generate creates a new world map
-func (ws *WorldSimulator) Generate(width, height float64, numParcels int, seed int64) {
+// dimension returns the named dimension's World, creating it (with the
+// default handler pipeline) if it doesn't exist yet.
+func (ws *WorldSimulator) dimension(name string) *World {
 	ws.mu.Lock()
 	defer ws.mu.Unlock()
 
-	if seed == 0 {
-		seed = time.Now().UnixNano()
+	w, ok := ws.worlds[name]
+	if !ok {
+		w = newWorld(name)
+		ws.worlds[name] = w
+	}
+	return w
+}
+
+// lookupDimension returns the named dimension's World without creating it,
+// or nil if it doesn't exist yet.
+func (ws *WorldSimulator) lookupDimension(name string) *World {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+	return ws.worlds[name]
+}
+
+// dimensionNames returns the name of every dimension the simulator currently
+// knows about.
+func (ws *WorldSimulator) dimensionNames() []string {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+	names := make([]string, 0, len(ws.worlds))
+	for name := range ws.worlds {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Resume reconstructs every dimension found in the store, if one is
+// configured. It reports whether any dimension was resumed.
+func (ws *WorldSimulator) Resume() (bool, error) {
+	if ws.store == nil {
+		return false, nil
+	}
+
+	dimensions, err := ws.store.ListDimensions()
+	if err != nil {
+		return false, fmt.Errorf("listing dimensions: %w", err)
+	}
+
+	for _, name := range dimensions {
+		seed, width, height, err := ws.store.LoadMeta(name)
+		if err != nil {
+			return false, fmt.Errorf("loading meta for dimension %q: %w", name, err)
+		}
+
+		parcels, err := ws.store.LoadAllParcels(name)
+		if err != nil {
+			return false, fmt.Errorf("loading parcels for dimension %q: %w", name, err)
+		}
+
+		entities, err := ws.store.LoadAllEntities(name)
+		if err != nil {
+			return false, fmt.Errorf("loading entities for dimension %q: %w", name, err)
+		}
+
+		ws.dimension(name).restore(seed, &world.Map{
+			Parcels:    parcels,
+			Entities:   entities,
+			Width:      width,
+			Height:     height,
+			LastUpdate: time.Now().UnixMilli(),
+		})
+
+		for id := range entities {
+			seedNextEntityID(id)
+		}
+
+		log.Printf("Resumed dimension %q from store: %d parcels", name, len(parcels))
+	}
+
+	return len(dimensions) > 0, nil
+}
+
+// Generate creates a new map for the named dimension.
+func (ws *WorldSimulator) Generate(dimension string, width, height float64, numParcels int, seed int64) {
+	w := ws.dimension(dimension)
+
+	// Capture the entities this dimension had before regenerating, so they
+	// can be purged from the store below; a fresh generation starts with no
+	// entities, and leaving the old ones persisted would resurrect them
+	// pointing at parcel IDs from the discarded generation on the next resume.
+	var staleEntityIDs []int64
+	if ws.store != nil {
+		w.WithMap(func(old *world.Map) {
+			for id := range old.Entities {
+				staleEntityIDs = append(staleEntityIDs, id)
+			}
+		})
+	}
+
+	log.Printf("Generating world map for dimension %q with seed: %d, parcels: %d", dimension, seed, numParcels)
+	m := w.Generate(width, height, numParcels, seed)
+
+	if ws.store != nil {
+		if err := ws.store.SaveMeta(dimension, w.seed, width, height); err != nil {
+			log.Printf("worldstore: saving meta for dimension %q: %v", dimension, err)
+		}
+		for id, p := range m.Parcels {
+			if err := ws.store.SaveParcel(dimension, id, p); err != nil {
+				log.Printf("worldstore: saving parcel %d in dimension %q: %v", id, dimension, err)
+			}
+		}
+		for _, id := range staleEntityIDs {
+			if err := ws.store.DeleteEntity(dimension, id); err != nil {
+				log.Printf("worldstore: deleting stale entity %d in dimension %q: %v", id, dimension, err)
+			}
+		}
 	}
-	ws.seed = seed
-	ws.numParcels = numParcels
 
-	log.Printf("Generating world map with seed: %d, parcels: %d", seed, numParcels)
+	log.Printf("World map generation complete for dimension %q: %d parcels", dimension, len(m.Parcels))
+	ws.broadcastKeyframe(dimension, w)
+}
 
+// generateWorld deterministically builds a world map from generation
+// parameters alone. It has no side effects, so it can be reused to
+// reconstruct a world during capture replay without touching a live
+// WorldSimulator.
+func generateWorld(width, height float64, numParcels int, seed int64) *world.Map {
 	rng := rand.New(rand.NewSource(seed))
 
 	// Generate parcels using simple grid-based approach with noise
-	parcels := make(map[int]*Parcel)
+	parcels := make(map[int]*world.Parcel)
 	perlinGen := perlin.NewPerlin(2, 2, 3, seed)
 
 	// Create a grid and add some randomness
@@ -105,11 +257,11 @@ func (ws *WorldSimulator) Generate(width, height float64, numParcels int, seed i
 			centerY := float64(row)*cellHeight + cellHeight/2 + offsetY
 
 			// Create vertices for hexagon-like shape
-			vertices := make([]Point, 6)
+			vertices := make([]world.Point, 6)
 			for i := 0; i < 6; i++ {
 				angle := float64(i) * math.Pi / 3
 				radius := math.Min(cellWidth, cellHeight) * 0.45
-				vertices[i] = Point{
+				vertices[i] = world.Point{
 					X: centerX + radius*math.Cos(angle),
 					Y: centerY + radius*math.Sin(angle),
 				}
@@ -126,10 +278,10 @@ func (ws *WorldSimulator) Generate(width, height float64, numParcels int, seed i
 			// Generate resources based on terrain
 			resources := generateResources(terrain, rng)
 
-			parcels[id] = &Parcel{
+			parcels[id] = &world.Parcel{
 				ID:          id,
 				Vertices:    vertices,
-				Center:      Point{X: centerX, Y: centerY},
+				Center:      world.Point{X: centerX, Y: centerY},
 				Terrain:     terrain,
 				Resources:   resources,
 				Neighbors:   []int{}, // Will be populated later
@@ -153,15 +305,13 @@ func (ws *WorldSimulator) Generate(width, height float64, numParcels int, seed i
 		}
 	}
 
-	ws.world = &WorldMap{
+	return &world.Map{
 		Parcels:    parcels,
+		Entities:   make(map[int64]*world.Entity),
 		Width:      width,
 		Height:     height,
 		LastUpdate: time.Now().UnixMilli(),
 	}
-
-	log.Printf("World map generation complete: %d parcels", len(parcels))
-	ws.broadcastWorldState()
 }
 
 func determineTerrain(elevation, moisture, temperature float64) string {
@@ -193,14 +343,14 @@ func determineTerrain(elevation, moisture, temperature float64) string {
 	return "grassland"
 }
 
-func generateResources(terrain string, rng *rand.Rand) []Resource {
-	resources := []Resource{}
+func generateResources(terrain string, rng *rand.Rand) []world.Resource {
+	resources := []world.Resource{}
 
 	// Resource generation based on terrain
 	switch terrain {
 	case "ocean", "shallow_water":
 		if rng.Float64() > 0.5 {
-			resources = append(resources, Resource{
+			resources = append(resources, world.Resource{
 				Type:       "fish",
 				Current:    rng.Float64() * 100,
 				Maximum:    100,
@@ -209,7 +359,7 @@ func generateResources(terrain string, rng *rand.Rand) []Resource {
 		}
 	case "forest", "jungle":
 		if rng.Float64() > 0.3 {
-			resources = append(resources, Resource{
+			resources = append(resources, world.Resource{
 				Type:       "wood",
 				Current:    rng.Float64() * 150,
 				Maximum:    150,
@@ -217,7 +367,7 @@ func generateResources(terrain string, rng *rand.Rand) []Resource {
 			})
 		}
 		if rng.Float64() > 0.6 {
-			resources = append(resources, Resource{
+			resources = append(resources, world.Resource{
 				Type:       "game",
 				Current:    rng.Float64() * 80,
 				Maximum:    80,
@@ -226,7 +376,7 @@ func generateResources(terrain string, rng *rand.Rand) []Resource {
 		}
 	case "mountain":
 		if rng.Float64() > 0.4 {
-			resources = append(resources, Resource{
+			resources = append(resources, world.Resource{
 				Type:       "stone",
 				Current:    rng.Float64() * 200,
 				Maximum:    200,
@@ -234,7 +384,7 @@ func generateResources(terrain string, rng *rand.Rand) []Resource {
 			})
 		}
 		if rng.Float64() > 0.7 {
-			resources = append(resources, Resource{
+			resources = append(resources, world.Resource{
 				Type:       "iron",
 				Current:    rng.Float64() * 100,
 				Maximum:    100,
@@ -242,7 +392,7 @@ func generateResources(terrain string, rng *rand.Rand) []Resource {
 			})
 		}
 		if rng.Float64() > 0.9 {
-			resources = append(resources, Resource{
+			resources = append(resources, world.Resource{
 				Type:       "gold",
 				Current:    rng.Float64() * 50,
 				Maximum:    50,
@@ -251,7 +401,7 @@ func generateResources(terrain string, rng *rand.Rand) []Resource {
 		}
 	case "desert":
 		if rng.Float64() > 0.8 {
-			resources = append(resources, Resource{
+			resources = append(resources, world.Resource{
 				Type:       "oil",
 				Current:    rng.Float64() * 120,
 				Maximum:    120,
@@ -260,7 +410,7 @@ func generateResources(terrain string, rng *rand.Rand) []Resource {
 		}
 	case "grassland":
 		if rng.Float64() > 0.4 {
-			resources = append(resources, Resource{
+			resources = append(resources, world.Resource{
 				Type:       "fertile_soil",
 				Current:    rng.Float64() * 100,
 				Maximum:    100,
@@ -268,7 +418,7 @@ func generateResources(terrain string, rng *rand.Rand) []Resource {
 			})
 		}
 		if rng.Float64() > 0.7 {
-			resources = append(resources, Resource{
+			resources = append(resources, world.Resource{
 				Type:       "water",
 				Current:    rng.Float64() * 80,
 				Maximum:    80,
@@ -277,7 +427,7 @@ func generateResources(terrain string, rng *rand.Rand) []Resource {
 		}
 	case "tundra":
 		if rng.Float64() > 0.8 {
-			resources = append(resources, Resource{
+			resources = append(resources, world.Resource{
 				Type:       "coal",
 				Current:    rng.Float64() * 100,
 				Maximum:    100,
@@ -289,76 +439,79 @@ func generateResources(terrain string, rng *rand.Rand) []Resource {
 	return resources
 }
 
-// Simulate updates world state
-func (ws *WorldSimulator) Simulate(deltaTime float64) {
-	ws.mu.Lock()
-	defer ws.mu.Unlock()
-
-	if ws.world == nil || !ws.isSimulating {
-		return
-	}
-
-	for _, parcel := range ws.world.Parcels {
-		for i := range parcel.Resources {
-			resource := &parcel.Resources[i]
-			resource.Current += resource.ChangeRate * deltaTime
-			if resource.Current > resource.Maximum {
-				resource.Current = resource.Maximum
-			}
-			if resource.Current < 0 {
-				resource.Current = 0
-			}
+// simulateDimension advances one dimension by a tick and fans the result
+// out to persistence, capture, and connected clients. The fan-out runs
+// while the dimension is still locked (see World.Simulate) so it can't race
+// with concurrent API writes to the same dimension, e.g. AddEntity.
+func (ws *WorldSimulator) simulateDimension(name string, w *World, deltaTime float64) {
+	w.Simulate(deltaTime, ws.otherDimensionParcel, func(ctx *TickContext) {
+		changedParcelIDs, _ := ws.broadcastDelta(name, ctx)
+		if ws.store != nil {
+			ws.persistTick(name, ctx, changedParcelIDs)
 		}
-	}
-
-	ws.world.LastUpdate = time.Now().UnixMilli()
-	ws.broadcastWorldState()
-}
-
-func (ws *WorldSimulator) StartSimulation() {
-	ws.mu.Lock()
-	ws.isSimulating = true
-	ws.mu.Unlock()
-	log.Println("Simulation started")
-}
+		ws.recordTick(name, ctx)
 
-func (ws *WorldSimulator) StopSimulation() {
-	ws.mu.Lock()
-	ws.isSimulating = false
-	ws.mu.Unlock()
-	log.Println("Simulation stopped")
+		for _, event := range ctx.Events {
+			ws.broadcastEvent(name, event)
+		}
+	})
 }
 
-func (ws *WorldSimulator) SetSpeed(speed float64) {
-	ws.mu.Lock()
-	ws.simulationSpeed = speed
-	ws.mu.Unlock()
-	log.Printf("Simulation speed set to: %.1fx", speed)
-}
+// persistTick writes the parcels broadcastDelta determined had actually
+// changed this tick to the store's append-only tick journal and keeps their
+// per-parcel records in sync, rather than re-diffing (or re-saving) every
+// parcel in the dimension on every tick.
+func (ws *WorldSimulator) persistTick(dimension string, ctx *TickContext, changedParcelIDs []int) {
+	deltas := make([]worldstore.ParcelDelta, 0, len(changedParcelIDs))
+	for _, id := range changedParcelIDs {
+		p, ok := ctx.World.Parcels[id]
+		if !ok {
+			continue
+		}
+		deltas = append(deltas, worldstore.ParcelDelta{
+			ParcelID:    id,
+			Elevation:   p.Elevation,
+			Moisture:    p.Moisture,
+			Temperature: p.Temperature,
+			Resources:   p.Resources,
+		})
+		if err := ws.store.SaveParcel(dimension, id, p); err != nil {
+			log.Printf("worldstore: saving parcel %d in dimension %q: %v", id, dimension, err)
+		}
+	}
 
-func (ws *WorldSimulator) GetWorld() *WorldMap {
-	ws.mu.RLock()
-	defer ws.mu.RUnlock()
-	return ws.world
-}
+	if len(deltas) > 0 {
+		if err := ws.store.AppendTick(dimension, ctx.Tick, deltas); err != nil {
+			log.Printf("worldstore: appending tick %d in dimension %q: %v", ctx.Tick, dimension, err)
+		}
+	}
 
-func (ws *WorldSimulator) IsSimulating() bool {
-	ws.mu.RLock()
-	defer ws.mu.RUnlock()
-	return ws.isSimulating
+	for id, e := range ctx.World.Entities {
+		if err := ws.store.SaveEntity(dimension, id, e); err != nil {
+			log.Printf("worldstore: saving entity %d in dimension %q: %v", id, dimension, err)
+		}
+	}
 }
 
-func (ws *WorldSimulator) GetSpeed() float64 {
+// otherDimensionParcel resolves a dimension by name and, if it exists,
+// safely reads and mutates one of its parcels under that dimension's own
+// lock, for handlers that reach across dimensions via a portal. It never
+// creates a dimension that doesn't already exist.
+func (ws *WorldSimulator) otherDimensionParcel(name string, parcelID int, fn func(p *world.Parcel)) bool {
 	ws.mu.RLock()
-	defer ws.mu.RUnlock()
-	return ws.simulationSpeed
+	w, ok := ws.worlds[name]
+	ws.mu.RUnlock()
+	if !ok {
+		return false
+	}
+	return w.WithParcel(parcelID, fn)
 }
 
-func (ws *WorldSimulator) addClient(ch chan string) {
+func (ws *WorldSimulator) addClient(c *ClientState) {
 	ws.clientsMu.Lock()
 	defer ws.clientsMu.Unlock()
-	ws.clients[ch] = true
-	log.Printf("Client connected. Total clients: %d", len(ws.clients))
+	ws.clients[c.ch] = c
+	log.Printf("Client connected (dimension %q). Total clients: %d", c.dimension, len(ws.clients))
 }
 
 func (ws *WorldSimulator) removeClient(ch chan string) {
@@ -369,7 +522,17 @@ func (ws *WorldSimulator) removeClient(ch chan string) {
 	log.Printf("Client disconnected. Total clients: %d", len(ws.clients))
 }
 
-func (ws *WorldSimulator) broadcastWorldState() {
+// wantsDimension reports whether a client subscribed to dimension filter
+// `want` (empty means "all") should receive a message about `dimension`.
+func wantsDimension(want, dimension string) bool {
+	return want == "" || want == dimension
+}
+
+// broadcastEvent sends a single handler-emitted event to clients subscribed
+// to that dimension, as its own SSE message, so the frontend can react to
+// discrete happenings ("fire_started", "settlement_founded", ...) without
+// diffing the whole world.
+func (ws *WorldSimulator) broadcastEvent(dimension string, event Event) {
 	ws.clientsMu.Lock()
 	defer ws.clientsMu.Unlock()
 
@@ -378,37 +541,60 @@ func (ws *WorldSimulator) broadcastWorldState() {
 	}
 
 	data, err := json.Marshal(map[string]interface{}{
-		"type":  "world_update",
-		"world": ws.world,
+		"type":      event.Type,
+		"dimension": dimension,
+		"payload":   event.Payload,
 	})
 	if err != nil {
-		log.Printf("Error marshaling world state: %v", err)
+		log.Printf("Error marshaling event %q: %v", event.Type, err)
 		return
 	}
 
 	message := fmt.Sprintf("data: %s\n\n", string(data))
 
-	// Track clients to remove if they're not reading
-	var toRemove []chan string
-
-	for ch := range ws.clients {
+	for ch, c := range ws.clients {
+		if !wantsDimension(c.dimension, dimension) {
+			continue
+		}
 		select {
 		case ch <- message:
-			// Message sent successfully
 		default:
-			// Client channel is full, mark for removal
-			log.Printf("Client channel full, removing slow client")
-			toRemove = append(toRemove, ch)
+			log.Printf("Client channel full, dropping event %q", event.Type)
 		}
 	}
+}
+
+func (ws *WorldSimulator) broadcastSimulationState(dimension string, w *World) {
+	ws.clientsMu.Lock()
+	defer ws.clientsMu.Unlock()
 
-	// Remove slow/unresponsive clients
-	for _, ch := range toRemove {
-		delete(ws.clients, ch)
-		close(ch)
+	data, err := json.Marshal(map[string]interface{}{
+		"type":         "simulation_state",
+		"dimension":    dimension,
+		"isSimulating": w.IsSimulating(),
+		"speed":        w.GetSpeed(),
+	})
+	if err != nil {
+		log.Printf("Error marshaling simulation state: %v", err)
+		return
+	}
+
+	message := fmt.Sprintf("data: %s\n\n", string(data))
+	for ch, c := range ws.clients {
+		if !wantsDimension(c.dimension, dimension) {
+			continue
+		}
+		select {
+		case ch <- message:
+		default:
+			// Skip if channel is full
+		}
 	}
 }
 
+// Run ticks every dimension on a shared 100ms schedule. Dimensions are
+// ticked sequentially, not concurrently, so handlers are free to reach
+// across dimensions (e.g. through a portal parcel) without extra locking.
 func (ws *WorldSimulator) Run() {
 	ticker := time.NewTicker(100 * time.Millisecond)
 	defer ticker.Stop()
@@ -417,14 +603,31 @@ func (ws *WorldSimulator) Run() {
 
 	for range ticker.C {
 		now := time.Now()
-		if ws.IsSimulating() {
-			deltaTime := now.Sub(lastUpdate).Seconds() * ws.GetSpeed()
-			ws.Simulate(deltaTime)
-			lastUpdate = now
-		} else {
-			// Reset lastUpdate when paused to prevent jump when resuming
+
+		ws.mu.RLock()
+		worlds := make(map[string]*World, len(ws.worlds))
+		for name, w := range ws.worlds {
+			worlds[name] = w
+		}
+		ws.mu.RUnlock()
+
+		anySimulating := false
+		for name, w := range worlds {
+			if !w.IsSimulating() {
+				continue
+			}
+			anySimulating = true
+			deltaTime := now.Sub(lastUpdate).Seconds() * w.GetSpeed()
+			ws.simulateDimension(name, w, deltaTime)
+		}
+
+		if !anySimulating {
+			// Reset lastUpdate when nothing is simulating to prevent a
+			// jump when one resumes.
 			lastUpdate = now
+			continue
 		}
+		lastUpdate = now
 	}
 }
 
@@ -436,40 +639,62 @@ func (ws *WorldSimulator) handleSSE(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Connection", "keep-alive")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 
-	flusher, ok := w.(http.Flusher)
+	baseFlusher, ok := w.(http.Flusher)
 	if !ok {
 		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
 		return
 	}
 
+	flusher := baseFlusher
+	if acceptsGzip(r) {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		gzw := &gzipFlushWriter{ResponseWriter: w, gz: gz, base: baseFlusher}
+		w = gzw
+		flusher = gzw
+	}
+
+	dimensionFilter := r.URL.Query().Get("dimension")
+	afterKeyframe, hasAfterKeyframe := parseUintQuery(r, "afterKeyframe")
+
 	// Create client channel
-	messageChan := make(chan string, 10)
-	ws.addClient(messageChan)
-	defer ws.removeClient(messageChan)
+	c := &ClientState{ch: make(chan string, 10), dimension: dimensionFilter}
+	ws.addClient(c)
+	defer ws.removeClient(c.ch)
+
+	// Send initial state for every dimension the client is subscribed to.
+	ws.mu.RLock()
+	dimensions := make(map[string]*World, len(ws.worlds))
+	for name, dw := range ws.worlds {
+		if wantsDimension(dimensionFilter, name) {
+			dimensions[name] = dw
+		}
+	}
+	ws.mu.RUnlock()
 
-	// Send initial world state
-	if world := ws.GetWorld(); world != nil {
+	for name, dw := range dimensions {
+		if dw.GetMap() != nil {
+			messages, keyframeID := ws.resyncMessages(name, dw, afterKeyframe, hasAfterKeyframe)
+			for _, message := range messages {
+				fmt.Fprint(w, message)
+			}
+			c.setLastKeyframeID(name, keyframeID)
+		}
 		data, _ := json.Marshal(map[string]interface{}{
-			"type":  "world_update",
-			"world": world,
+			"type":         "simulation_state",
+			"dimension":    name,
+			"isSimulating": dw.IsSimulating(),
+			"speed":        dw.GetSpeed(),
 		})
 		fmt.Fprintf(w, "data: %s\n\n", string(data))
-		flusher.Flush()
 	}
-
-	// Send simulation state
-	data, _ := json.Marshal(map[string]interface{}{
-		"type":         "simulation_state",
-		"isSimulating": ws.IsSimulating(),
-		"speed":        ws.GetSpeed(),
-	})
-	fmt.Fprintf(w, "data: %s\n\n", string(data))
 	flusher.Flush()
 
 	// Stream updates
 	for {
 		select {
-		case msg := <-messageChan:
+		case msg := <-c.ch:
 			fmt.Fprint(w, msg)
 			flusher.Flush()
 		case <-r.Context().Done():
@@ -478,74 +703,105 @@ func (ws *WorldSimulator) handleSSE(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// parseUintQuery parses query parameter key as a uint64, reporting whether
+// it was present and valid.
+func parseUintQuery(r *http.Request, key string) (uint64, bool) {
+	s := r.URL.Query().Get(key)
+	if s == "" {
+		return 0, false
+	}
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
 func (ws *WorldSimulator) handleGenerateMap(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ws.recordAPICall(r.Method, r.URL.Path, body)
+
 	var req struct {
-		NumParcels int   `json:"numParcels"`
-		Seed       int64 `json:"seed"`
+		Dimension  string `json:"dimension"`
+		NumParcels int    `json:"numParcels"`
+		Seed       int64  `json:"seed"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := json.Unmarshal(body, &req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	if req.Dimension == "" {
+		req.Dimension = DefaultDimension
+	}
+	if !validDimensionName(req.Dimension) {
+		http.Error(w, fmt.Sprintf("invalid dimension name %q", req.Dimension), http.StatusBadRequest)
+		return
+	}
 	if req.NumParcels == 0 {
 		req.NumParcels = 500
 	}
 
-	ws.Generate(1200, 800, req.NumParcels, req.Seed)
+	ws.Generate(req.Dimension, 1200, 800, req.NumParcels, req.Seed)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
 
-func (ws *WorldSimulator) broadcastSimulationState() {
-	ws.clientsMu.Lock()
-	defer ws.clientsMu.Unlock()
+func (ws *WorldSimulator) handleToggleSimulation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
-	data, err := json.Marshal(map[string]interface{}{
-		"type":         "simulation_state",
-		"isSimulating": ws.IsSimulating(),
-		"speed":        ws.GetSpeed(),
-	})
+	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Printf("Error marshaling simulation state: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	ws.recordAPICall(r.Method, r.URL.Path, body)
 
-	message := fmt.Sprintf("data: %s\n\n", string(data))
-	for ch := range ws.clients {
-		select {
-		case ch <- message:
-		default:
-			// Skip if channel is full
+	var req struct {
+		Dimension string `json:"dimension"`
+	}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
 	}
-}
-
-func (ws *WorldSimulator) handleToggleSimulation(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	if req.Dimension == "" {
+		req.Dimension = DefaultDimension
+	}
+	if !validDimensionName(req.Dimension) {
+		http.Error(w, fmt.Sprintf("invalid dimension name %q", req.Dimension), http.StatusBadRequest)
 		return
 	}
 
-	if ws.IsSimulating() {
-		ws.StopSimulation()
+	dw := ws.dimension(req.Dimension)
+	if dw.IsSimulating() {
+		dw.StopSimulation()
 	} else {
-		ws.StartSimulation()
+		dw.StartSimulation()
 	}
 
-	ws.broadcastSimulationState()
+	ws.broadcastSimulationState(req.Dimension, dw)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status":       "ok",
-		"isSimulating": ws.IsSimulating(),
+		"dimension":    req.Dimension,
+		"isSimulating": dw.IsSimulating(),
 	})
 }
 
@@ -555,17 +811,33 @@ func (ws *WorldSimulator) handleSetSpeed(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ws.recordAPICall(r.Method, r.URL.Path, body)
+
 	var req struct {
-		Speed float64 `json:"speed"`
+		Dimension string  `json:"dimension"`
+		Speed     float64 `json:"speed"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := json.Unmarshal(body, &req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if req.Dimension == "" {
+		req.Dimension = DefaultDimension
+	}
+	if !validDimensionName(req.Dimension) {
+		http.Error(w, fmt.Sprintf("invalid dimension name %q", req.Dimension), http.StatusBadRequest)
+		return
+	}
 
-	ws.SetSpeed(req.Speed)
-	ws.broadcastSimulationState()
+	dw := ws.dimension(req.Dimension)
+	dw.SetSpeed(req.Speed)
+	ws.broadcastSimulationState(req.Dimension, dw)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
@@ -573,12 +845,22 @@ func (ws *WorldSimulator) handleSetSpeed(w http.ResponseWriter, r *http.Request)
 
 func main() {
 	port := flag.Int("port", 8080, "Port to listen on")
+	storePath := flag.String("store", "", "Path to a LevelDB directory for persisting world state across restarts (optional)")
 	flag.Parse()
 
-	simulator := NewWorldSimulator()
+	simulator, err := NewWorldSimulator(*storePath)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	// Generate initial map
-	simulator.Generate(1200, 800, 500, 0)
+	resumed, err := simulator.Resume()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !resumed {
+		// Generate the initial overworld map
+		simulator.Generate(DefaultDimension, 1200, 800, 500, 0)
+	}
 
 	// Start simulation loop
 	go simulator.Run()
@@ -588,6 +870,11 @@ func main() {
 	http.HandleFunc("/api/generate", simulator.handleGenerateMap)
 	http.HandleFunc("/api/toggle-simulation", simulator.handleToggleSimulation)
 	http.HandleFunc("/api/set-speed", simulator.handleSetSpeed)
+	http.HandleFunc("/api/capture/start", simulator.handleCaptureStart)
+	http.HandleFunc("/api/capture/stop", simulator.handleCaptureStop)
+	http.HandleFunc("/api/replay", simulator.handleReplay)
+	http.HandleFunc("/api/entities/", simulator.handleEntities)
+	http.HandleFunc("/api/portals/link", simulator.handlePortalLink)
 
 	// Serve static files from dist directory
 	fs := http.FileServer(http.Dir("./dist"))
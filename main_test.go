@@ -0,0 +1,146 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/wzshiming/civilization/world"
+)
+
+// TestGeneratePurgesStaleEntities reproduces resuming after a regenerate:
+// an entity persisted under the old generation must not resurface once the
+// dimension has been regenerated.
+func TestGeneratePurgesStaleEntities(t *testing.T) {
+	ws, err := NewWorldSimulator(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewWorldSimulator: %v", err)
+	}
+
+	ws.Generate("overworld", 100, 100, 10, 1)
+
+	w := ws.lookupDimension("overworld")
+	m := w.GetMap()
+	var parcelID int
+	for id := range m.Parcels {
+		parcelID = id
+		break
+	}
+
+	entity := &world.Entity{ID: newEntityID(), Type: "herd", ParcelID: parcelID}
+	w.AddEntity(entity)
+	if err := ws.store.SaveEntity("overworld", entity.ID, entity); err != nil {
+		t.Fatalf("SaveEntity: %v", err)
+	}
+
+	ws.Generate("overworld", 100, 100, 10, 2)
+
+	entities, err := ws.store.LoadAllEntities("overworld")
+	if err != nil {
+		t.Fatalf("LoadAllEntities: %v", err)
+	}
+	if len(entities) != 0 {
+		t.Fatalf("LoadAllEntities returned %d stale entities after regenerate, want 0: %#v", len(entities), entities)
+	}
+}
+
+// TestResumeSeedsNextEntityIDPastPersisted reproduces a real restart: an
+// entity is spawned and persisted, the counter is reset to model a fresh
+// process, and Resume must advance it past the resumed entity's ID before
+// any new entity is spawned, or the two would collide in World.AddEntity.
+func TestResumeSeedsNextEntityIDPastPersisted(t *testing.T) {
+	dir := t.TempDir()
+
+	ws, err := NewWorldSimulator(dir)
+	if err != nil {
+		t.Fatalf("NewWorldSimulator: %v", err)
+	}
+	ws.Generate("overworld", 100, 100, 10, 1)
+
+	w := ws.lookupDimension("overworld")
+	m := w.GetMap()
+	var parcelID int
+	for id := range m.Parcels {
+		parcelID = id
+		break
+	}
+
+	entity := &world.Entity{ID: newEntityID(), Type: "herd", ParcelID: parcelID}
+	w.AddEntity(entity)
+	if err := ws.store.SaveEntity("overworld", entity.ID, entity); err != nil {
+		t.Fatalf("SaveEntity: %v", err)
+	}
+	if err := ws.store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	nextEntityID = 0 // model a fresh process, where the counter restarts at 0
+
+	ws2, err := NewWorldSimulator(dir)
+	if err != nil {
+		t.Fatalf("NewWorldSimulator: %v", err)
+	}
+	if _, err := ws2.Resume(); err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+
+	spawned := &world.Entity{ID: newEntityID(), Type: "herd", ParcelID: parcelID}
+	if spawned.ID == entity.ID {
+		t.Fatalf("newEntityID() = %d after Resume, collides with resumed entity %d", spawned.ID, entity.ID)
+	}
+}
+
+// TestPersistTickOnlyWritesChangedParcels checks that persistTick saves and
+// journals only the parcels it's told changed, rather than re-writing every
+// parcel in the dimension on every tick.
+func TestPersistTickOnlyWritesChangedParcels(t *testing.T) {
+	ws, err := NewWorldSimulator(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewWorldSimulator: %v", err)
+	}
+
+	ctx := &TickContext{
+		Tick: 1,
+		World: &world.Map{
+			Parcels: map[int]*world.Parcel{
+				1: {ID: 1, Elevation: 1},
+				2: {ID: 2, Elevation: 2},
+			},
+		},
+	}
+
+	ws.persistTick("overworld", ctx, []int{1})
+
+	if _, err := ws.store.LoadParcel("overworld", 1); err != nil {
+		t.Fatalf("LoadParcel(1): %v, want the changed parcel persisted", err)
+	}
+	if _, err := ws.store.LoadParcel("overworld", 2); err == nil {
+		t.Fatalf("LoadParcel(2) succeeded, want the unchanged parcel left unpersisted")
+	}
+
+	deltas, err := ws.store.LoadTick("overworld", 1)
+	if err != nil {
+		t.Fatalf("LoadTick: %v", err)
+	}
+	if len(deltas) != 1 || deltas[0].ParcelID != 1 {
+		t.Fatalf("tick deltas = %+v, want exactly parcel 1", deltas)
+	}
+}
+
+// TestPersistTickSkipsJournalWhenNothingChanged checks that a tick with no
+// changed parcels doesn't write an empty journal entry.
+func TestPersistTickSkipsJournalWhenNothingChanged(t *testing.T) {
+	ws, err := NewWorldSimulator(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewWorldSimulator: %v", err)
+	}
+
+	ctx := &TickContext{
+		Tick:  1,
+		World: &world.Map{Parcels: map[int]*world.Parcel{1: {ID: 1}}},
+	}
+
+	ws.persistTick("overworld", ctx, nil)
+
+	if _, err := ws.store.LoadTick("overworld", 1); err == nil {
+		t.Fatalf("LoadTick succeeded for a tick with no changed parcels, want it left unwritten")
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/wzshiming/civilization/world"
+)
+
+// handlePortalLink links a parcel in one dimension to a parcel in another,
+// bidirectionally, so PortalHandler will couple their elevation every tick
+// (e.g. underground mining affecting surface elevation). This is the only
+// way to populate Parcel.Portal through the running server; generation
+// never assigns one on its own.
+func (ws *WorldSimulator) handlePortalLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ws.recordAPICall(r.Method, r.URL.Path, body)
+
+	var req struct {
+		Dimension       string `json:"dimension"`
+		ParcelID        int    `json:"parcelId"`
+		LinkedDimension string `json:"linkedDimension"`
+		LinkedParcelID  int    `json:"linkedParcelId"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !validDimensionName(req.Dimension) {
+		http.Error(w, fmt.Sprintf("invalid dimension name %q", req.Dimension), http.StatusBadRequest)
+		return
+	}
+	if !validDimensionName(req.LinkedDimension) {
+		http.Error(w, fmt.Sprintf("invalid dimension name %q", req.LinkedDimension), http.StatusBadRequest)
+		return
+	}
+	if req.Dimension == req.LinkedDimension && req.ParcelID == req.LinkedParcelID {
+		http.Error(w, "cannot link a parcel to itself", http.StatusBadRequest)
+		return
+	}
+
+	a := ws.lookupDimension(req.Dimension)
+	if a == nil {
+		http.Error(w, fmt.Sprintf("dimension %q has no world generated yet", req.Dimension), http.StatusBadRequest)
+		return
+	}
+	b := ws.lookupDimension(req.LinkedDimension)
+	if b == nil {
+		http.Error(w, fmt.Sprintf("dimension %q has no world generated yet", req.LinkedDimension), http.StatusBadRequest)
+		return
+	}
+
+	// Check both parcels exist before mutating either, so a missing
+	// linkedParcelId can't leave req.ParcelID linked to nothing.
+	if !a.WithParcel(req.ParcelID, func(p *world.Parcel) {}) {
+		http.Error(w, fmt.Sprintf("parcel %d not found in dimension %q", req.ParcelID, req.Dimension), http.StatusBadRequest)
+		return
+	}
+	if !b.WithParcel(req.LinkedParcelID, func(p *world.Parcel) {}) {
+		http.Error(w, fmt.Sprintf("parcel %d not found in dimension %q", req.LinkedParcelID, req.LinkedDimension), http.StatusBadRequest)
+		return
+	}
+
+	a.WithParcel(req.ParcelID, func(p *world.Parcel) {
+		p.Portal = &world.Portal{Dimension: req.LinkedDimension, ParcelID: req.LinkedParcelID}
+		if ws.store != nil {
+			if err := ws.store.SaveParcel(req.Dimension, req.ParcelID, p); err != nil {
+				log.Printf("worldstore: saving parcel %d in dimension %q: %v", req.ParcelID, req.Dimension, err)
+			}
+		}
+	})
+	b.WithParcel(req.LinkedParcelID, func(p *world.Parcel) {
+		p.Portal = &world.Portal{Dimension: req.Dimension, ParcelID: req.ParcelID}
+		if ws.store != nil {
+			if err := ws.store.SaveParcel(req.LinkedDimension, req.LinkedParcelID, p); err != nil {
+				log.Printf("worldstore: saving parcel %d in dimension %q: %v", req.LinkedParcelID, req.LinkedDimension, err)
+			}
+		}
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
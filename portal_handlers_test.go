@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/wzshiming/civilization/world"
+)
+
+// TestHandlePortalLinkSetsBothSides checks that /api/portals/link is the
+// one way to populate Parcel.Portal through the running server: it links
+// both parcels to each other and persists the change, so PortalHandler has
+// something to couple on the next tick.
+func TestHandlePortalLinkSetsBothSides(t *testing.T) {
+	ws, err := NewWorldSimulator(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewWorldSimulator: %v", err)
+	}
+
+	a := ws.dimension("overworld")
+	a.restore(1, &world.Map{Parcels: map[int]*world.Parcel{10: {ID: 10}}})
+	b := ws.dimension("underground")
+	b.restore(1, &world.Map{Parcels: map[int]*world.Parcel{20: {ID: 20}}})
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"dimension":       "overworld",
+		"parcelId":        10,
+		"linkedDimension": "underground",
+		"linkedParcelId":  20,
+	})
+	req := httptest.NewRequest("POST", "/api/portals/link", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	ws.handlePortalLink(rec, req)
+
+	if rec.Code != 200 {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+
+	portalA := a.GetMap().Parcels[10].Portal
+	if portalA == nil || portalA.Dimension != "underground" || portalA.ParcelID != 20 {
+		t.Fatalf("overworld parcel 10 Portal = %+v, want it linked to underground/20", portalA)
+	}
+	portalB := b.GetMap().Parcels[20].Portal
+	if portalB == nil || portalB.Dimension != "overworld" || portalB.ParcelID != 10 {
+		t.Fatalf("underground parcel 20 Portal = %+v, want it linked to overworld/10", portalB)
+	}
+
+	if _, err := ws.store.LoadParcel("overworld", 10); err != nil {
+		t.Fatalf("LoadParcel(overworld, 10): %v, want the linked parcel persisted", err)
+	}
+	if _, err := ws.store.LoadParcel("underground", 20); err != nil {
+		t.Fatalf("LoadParcel(underground, 20): %v, want the linked parcel persisted", err)
+	}
+}
+
+// TestHandlePortalLinkRejectsUnknownDimension checks that linking to a
+// dimension with no world generated yet fails instead of silently creating
+// an empty one.
+func TestHandlePortalLinkRejectsUnknownDimension(t *testing.T) {
+	ws, err := NewWorldSimulator(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewWorldSimulator: %v", err)
+	}
+	a := ws.dimension("overworld")
+	a.restore(1, &world.Map{Parcels: map[int]*world.Parcel{10: {ID: 10}}})
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"dimension":       "overworld",
+		"parcelId":        10,
+		"linkedDimension": "nowhere",
+		"linkedParcelId":  20,
+	})
+	req := httptest.NewRequest("POST", "/api/portals/link", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	ws.handlePortalLink(rec, req)
+
+	if rec.Code != 400 {
+		t.Fatalf("status = %d, want 400 for an unknown dimension", rec.Code)
+	}
+	if a.GetMap().Parcels[10].Portal != nil {
+		t.Fatalf("Portal = %+v, want no link left behind after a rejected request", a.GetMap().Parcels[10].Portal)
+	}
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/wzshiming/civilization/world"
+)
+
+// keyframeInterval is how many simulation ticks pass between full world
+// snapshots ("keyframes") broadcast to SSE clients. Between keyframes, only
+// parcels whose elevation, moisture, temperature, or resources actually
+// changed are sent, which is what keeps a many-hundred-parcel world's
+// steady-state broadcast small.
+const keyframeInterval = 50
+
+// deltaRingSize bounds how many of a dimension's most recent delta messages
+// are kept in memory, covering one keyframe interval's worth of history so a
+// client reconnecting shortly after a drop can resync from the ring instead
+// of needing a full keyframe.
+const deltaRingSize = keyframeInterval
+
+// dimensionBroadcastState is the delta/keyframe protocol's bookkeeping for
+// one dimension: the last values broadcast (to diff future ticks against),
+// the current keyframe epoch, and a ring buffer of the deltas sent since
+// that keyframe.
+type dimensionBroadcastState struct {
+	mu sync.Mutex
+
+	snapshot           map[int]parcelSnapshot
+	ticksSinceKeyframe int
+	keyframeID         uint64
+	ring               []ringFrame
+}
+
+// parcelSnapshot is the subset of a parcel's fields that change over a
+// simulation's lifetime; vertices, center, terrain, and neighbors are fixed
+// at generation time and never need to be diffed or resent.
+type parcelSnapshot struct {
+	Elevation   float64
+	Moisture    float64
+	Temperature float64
+	Resources   []world.Resource
+}
+
+func snapshotOf(p *world.Parcel) parcelSnapshot {
+	resources := make([]world.Resource, len(p.Resources))
+	copy(resources, p.Resources)
+	return parcelSnapshot{
+		Elevation:   p.Elevation,
+		Moisture:    p.Moisture,
+		Temperature: p.Temperature,
+		Resources:   resources,
+	}
+}
+
+func (s parcelSnapshot) equal(o parcelSnapshot) bool {
+	return s.Elevation == o.Elevation &&
+		s.Moisture == o.Moisture &&
+		s.Temperature == o.Temperature &&
+		reflect.DeepEqual(s.Resources, o.Resources)
+}
+
+// parcelDeltaPayload is what's sent over the wire for a changed parcel: just
+// the fields that can change, keyed by parcel ID in the delta message.
+type parcelDeltaPayload struct {
+	Elevation   float64          `json:"elevation"`
+	Moisture    float64          `json:"moisture"`
+	Temperature float64          `json:"temperature"`
+	Resources   []world.Resource `json:"resources"`
+}
+
+// ringFrame is one delta message retained for resync, tagged with the
+// keyframe epoch it belongs to.
+type ringFrame struct {
+	keyframeID uint64
+	message    string
+}
+
+// dimensionBroadcastState returns (creating if necessary) the broadcast
+// bookkeeping for a dimension.
+func (ws *WorldSimulator) dimensionBroadcastState(dimension string) *dimensionBroadcastState {
+	ws.broadcastMu.Lock()
+	defer ws.broadcastMu.Unlock()
+	s, ok := ws.broadcastState[dimension]
+	if !ok {
+		s = &dimensionBroadcastState{}
+		ws.broadcastState[dimension] = s
+	}
+	return s
+}
+
+// broadcastDelta sends one dimension's tick result to subscribed SSE
+// clients using the delta/keyframe protocol: a full snapshot every
+// keyframeInterval ticks, and only the changed parcels in between. It
+// returns the IDs of the parcels it decided had changed (every parcel, on a
+// keyframe tick), so callers that need to persist only what changed - like
+// persistTick - don't have to redo this diff themselves.
+func (ws *WorldSimulator) broadcastDelta(dimension string, ctx *TickContext) (changedParcelIDs []int, isKeyframe bool) {
+	state := ws.dimensionBroadcastState(dimension)
+
+	state.mu.Lock()
+	state.ticksSinceKeyframe++
+	isKeyframe = state.snapshot == nil || state.ticksSinceKeyframe >= keyframeInterval
+
+	var message string
+	var keyframeID uint64
+
+	if isKeyframe {
+		state.keyframeID++
+		keyframeID = state.keyframeID
+		message = encodeSSE(map[string]interface{}{
+			"type":       "keyframe",
+			"dimension":  dimension,
+			"keyframeId": keyframeID,
+			"tick":       ctx.Tick,
+			"world":      ctx.World,
+		})
+		state.snapshot = make(map[int]parcelSnapshot, len(ctx.World.Parcels))
+		changedParcelIDs = make([]int, 0, len(ctx.World.Parcels))
+		for id, p := range ctx.World.Parcels {
+			state.snapshot[id] = snapshotOf(p)
+			changedParcelIDs = append(changedParcelIDs, id)
+		}
+		state.ticksSinceKeyframe = 0
+		state.ring = state.ring[:0]
+	} else {
+		keyframeID = state.keyframeID
+		changed := make(map[int]parcelDeltaPayload)
+		for id, p := range ctx.World.Parcels {
+			next := snapshotOf(p)
+			if prev, ok := state.snapshot[id]; ok && prev.equal(next) {
+				continue
+			}
+			state.snapshot[id] = next
+			changed[id] = parcelDeltaPayload{
+				Elevation:   next.Elevation,
+				Moisture:    next.Moisture,
+				Temperature: next.Temperature,
+				Resources:   next.Resources,
+			}
+			changedParcelIDs = append(changedParcelIDs, id)
+		}
+		if len(changed) == 0 {
+			state.mu.Unlock()
+			return changedParcelIDs, isKeyframe
+		}
+		message = encodeSSE(map[string]interface{}{
+			"type":       "delta",
+			"dimension":  dimension,
+			"keyframeId": keyframeID,
+			"tick":       ctx.Tick,
+			"parcels":    changed,
+		})
+		state.ring = append(state.ring, ringFrame{keyframeID: keyframeID, message: message})
+		if len(state.ring) > deltaRingSize {
+			state.ring = state.ring[len(state.ring)-deltaRingSize:]
+		}
+	}
+	state.mu.Unlock()
+
+	if message == "" {
+		return changedParcelIDs, isKeyframe
+	}
+	ws.fanOut(dimension, isKeyframe, keyframeID, message)
+	return changedParcelIDs, isKeyframe
+}
+
+// resyncMessages returns the SSE messages needed to bring a (re)connecting
+// client up to date for a dimension: the buffered deltas since its last
+// known keyframe if they're still in the ring, or a fresh full keyframe
+// otherwise. The keyframe is built through dw.WithMap so the encode can't
+// race against that dimension's own tick or a cross-dimension portal write.
+// dw.WithMap is called with state.mu released: a tick takes w.mu then
+// state.mu (via Simulate -> broadcastDelta), so holding state.mu while
+// acquiring w.mu here would invert that order and risk deadlock.
+func (ws *WorldSimulator) resyncMessages(dimension string, dw *World, afterKeyframe uint64, hasAfter bool) ([]string, uint64) {
+	state := ws.dimensionBroadcastState(dimension)
+
+	state.mu.Lock()
+	if hasAfter && afterKeyframe == state.keyframeID && len(state.ring) > 0 {
+		messages := make([]string, len(state.ring))
+		for i, f := range state.ring {
+			messages[i] = f.message
+		}
+		state.mu.Unlock()
+		return messages, state.keyframeID
+	}
+	keyframeID := state.keyframeID
+	state.mu.Unlock()
+
+	var message string
+	dw.WithMap(func(m *world.Map) {
+		message = encodeSSE(map[string]interface{}{
+			"type":       "keyframe",
+			"dimension":  dimension,
+			"keyframeId": keyframeID,
+			"world":      m,
+		})
+	})
+	return []string{message}, keyframeID
+}
+
+// broadcastKeyframe forces a fresh full keyframe for a dimension and sends
+// it to every subscribed client. Used outside the regular tick loop, e.g.
+// right after (re)generating a world. It reads dw's map through WithMap so
+// the encode can't race against a tick starting the moment Generate
+// returns. WithMap is called with state.mu released: a tick takes w.mu
+// then state.mu (via Simulate -> broadcastDelta), so holding state.mu
+// while acquiring w.mu here would invert that order and risk deadlock.
+func (ws *WorldSimulator) broadcastKeyframe(dimension string, dw *World) {
+	state := ws.dimensionBroadcastState(dimension)
+
+	state.mu.Lock()
+	state.keyframeID++
+	keyframeID := state.keyframeID
+	state.mu.Unlock()
+
+	var message string
+	var snapshot map[int]parcelSnapshot
+	dw.WithMap(func(m *world.Map) {
+		message = encodeSSE(map[string]interface{}{
+			"type":       "keyframe",
+			"dimension":  dimension,
+			"keyframeId": keyframeID,
+			"world":      m,
+		})
+		snapshot = make(map[int]parcelSnapshot, len(m.Parcels))
+		for id, p := range m.Parcels {
+			snapshot[id] = snapshotOf(p)
+		}
+	})
+
+	state.mu.Lock()
+	state.snapshot = snapshot
+	state.ticksSinceKeyframe = 0
+	state.ring = state.ring[:0]
+	state.mu.Unlock()
+
+	if message == "" {
+		return
+	}
+	ws.fanOut(dimension, true, keyframeID, message)
+}
+
+// fanOut sends message to every client subscribed to dimension, applying
+// the slow-client backpressure policy: a client whose channel is full on a
+// delta is marked behind and skipped until the next keyframe catches it up,
+// rather than being disconnected. Only a client whose channel is still full
+// on that keyframe is dropped.
+func (ws *WorldSimulator) fanOut(dimension string, isKeyframe bool, keyframeID uint64, message string) {
+	ws.clientsMu.Lock()
+	defer ws.clientsMu.Unlock()
+
+	var toRemove []chan string
+
+	for ch, c := range ws.clients {
+		if !wantsDimension(c.dimension, dimension) {
+			continue
+		}
+		if !isKeyframe && c.isBehind(dimension) {
+			continue
+		}
+		select {
+		case ch <- message:
+			if isKeyframe {
+				c.setBehind(dimension, false)
+				c.setLastKeyframeID(dimension, keyframeID)
+			}
+		default:
+			if isKeyframe {
+				log.Printf("Client channel full on keyframe, removing stuck client")
+				toRemove = append(toRemove, ch)
+			} else {
+				log.Printf("Client channel full, client will resync on next keyframe")
+				c.setBehind(dimension, true)
+			}
+		}
+	}
+
+	for _, ch := range toRemove {
+		delete(ws.clients, ch)
+		close(ch)
+	}
+}
+
+// encodeSSE marshals v as JSON and wraps it in an SSE "data:" frame. It
+// returns "" (a no-op message) if marshaling fails.
+func encodeSSE(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Error marshaling SSE message: %v", err)
+		return ""
+	}
+	return fmt.Sprintf("data: %s\n\n", data)
+}
+
+// acceptsGzip reports whether the client indicated it can decode a
+// gzip-encoded response body.
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range r.Header.Values("Accept-Encoding") {
+		if strings.Contains(enc, "gzip") {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipFlushWriter wraps an http.ResponseWriter so every Write passes through
+// a gzip.Writer, while Flush still flushes both the gzip buffer and the
+// underlying connection — needed because SSE relies on each message being
+// pushed out immediately rather than buffered.
+type gzipFlushWriter struct {
+	http.ResponseWriter
+	gz   *gzip.Writer
+	base http.Flusher
+}
+
+func (g *gzipFlushWriter) Write(b []byte) (int, error) {
+	return g.gz.Write(b)
+}
+
+func (g *gzipFlushWriter) Flush() {
+	g.gz.Flush()
+	g.base.Flush()
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wzshiming/civilization/world"
+)
+
+func newTestSimulator(t *testing.T) *WorldSimulator {
+	t.Helper()
+	ws, err := NewWorldSimulator("")
+	if err != nil {
+		t.Fatalf("NewWorldSimulator: %v", err)
+	}
+	return ws
+}
+
+// TestBroadcastDeltaSendsKeyframeThenDeltas checks the core of the
+// delta/keyframe protocol: the first tick for a dimension is always a full
+// keyframe, an unchanged tick after that sends nothing, and a tick with a
+// changed parcel sends only that parcel as a delta.
+func TestBroadcastDeltaSendsKeyframeThenDeltas(t *testing.T) {
+	ws := newTestSimulator(t)
+	parcels := map[int]*world.Parcel{
+		1: {ID: 1, Elevation: 0.1},
+		2: {ID: 2, Elevation: 0.2},
+	}
+	m := &world.Map{Parcels: parcels}
+
+	msg1 := captureBroadcast(ws, "overworld", &TickContext{World: m, Tick: 1})
+	if !strings.Contains(msg1, `"type":"keyframe"`) {
+		t.Fatalf("first broadcast = %q, want a keyframe", msg1)
+	}
+
+	msg2 := captureBroadcast(ws, "overworld", &TickContext{World: m, Tick: 2})
+	if msg2 != "" {
+		t.Fatalf("unchanged tick broadcast = %q, want no message", msg2)
+	}
+
+	parcels[1].Elevation = 0.5
+	msg3 := captureBroadcast(ws, "overworld", &TickContext{World: m, Tick: 3})
+	if !strings.Contains(msg3, `"type":"delta"`) {
+		t.Fatalf("changed tick broadcast = %q, want a delta", msg3)
+	}
+	if !strings.Contains(msg3, `"1":`) || strings.Contains(msg3, `"2":`) {
+		t.Fatalf("delta = %q, want only parcel 1, not parcel 2", msg3)
+	}
+}
+
+// TestBroadcastDeltaForcesKeyframeOnInterval checks that a keyframe is
+// re-sent every keyframeInterval ticks even with no changes, so a
+// reconnecting client isn't stuck waiting on the ring buffer forever.
+func TestBroadcastDeltaForcesKeyframeOnInterval(t *testing.T) {
+	ws := newTestSimulator(t)
+	m := &world.Map{Parcels: map[int]*world.Parcel{1: {ID: 1}}}
+
+	captureBroadcast(ws, "overworld", &TickContext{World: m, Tick: 1})
+	var lastMsg string
+	for tick := uint64(2); tick <= keyframeInterval+1; tick++ {
+		lastMsg = captureBroadcast(ws, "overworld", &TickContext{World: m, Tick: tick})
+	}
+
+	if !strings.Contains(lastMsg, `"type":"keyframe"`) {
+		t.Fatalf("tick %d broadcast = %q, want a forced keyframe", keyframeInterval+1, lastMsg)
+	}
+}
+
+// captureBroadcast calls broadcastDelta with a single subscribed client and
+// returns the message it received, or "" if none was sent.
+func captureBroadcast(ws *WorldSimulator, dimension string, ctx *TickContext) string {
+	ch := make(chan string, 1)
+	ws.clients[ch] = &ClientState{ch: ch}
+	defer delete(ws.clients, ch)
+
+	ws.broadcastDelta(dimension, ctx)
+
+	select {
+	case msg := <-ch:
+		return msg
+	default:
+		return ""
+	}
+}
+
+// TestFanOutSlowClientBackpressure checks the slow-client policy: a client
+// whose channel is full on a delta is marked behind (not disconnected) and
+// skipped until it's caught up by a keyframe; only a client still full on
+// that keyframe is dropped.
+func TestFanOutSlowClientBackpressure(t *testing.T) {
+	ws := newTestSimulator(t)
+	ch := make(chan string) // unbuffered: any send blocks/fails immediately
+	client := &ClientState{ch: ch, dimension: "overworld"}
+	ws.clients[ch] = client
+
+	ws.fanOut("overworld", false, 0, "data: delta\n\n")
+	if !client.isBehind("overworld") {
+		t.Fatalf("client.isBehind(\"overworld\") = false after a dropped delta, want true")
+	}
+	if _, ok := ws.clients[ch]; !ok {
+		t.Fatalf("client was removed after a dropped delta, want it kept for resync")
+	}
+
+	ws.fanOut("overworld", true, 1, "data: keyframe\n\n")
+	if _, ok := ws.clients[ch]; ok {
+		t.Fatalf("client was kept after a dropped keyframe, want it removed")
+	}
+}
+
+// TestFanOutBehindAndKeyframeAreIsolatedPerDimension checks that an
+// unfiltered client (subscribed to every dimension) tracks "behind" per
+// dimension: falling behind on one dimension's deltas must not be cleared
+// by an unrelated dimension's keyframe landing successfully, or the client
+// would resume receiving that dimension's deltas without ever having
+// received its catch-up keyframe.
+func TestFanOutBehindAndKeyframeAreIsolatedPerDimension(t *testing.T) {
+	ws := newTestSimulator(t)
+	ch := make(chan string, 1)
+	client := &ClientState{ch: ch} // dimension "" means subscribed to every dimension
+	ws.clients[ch] = client
+
+	// Fill the channel so a delta for "overworld" is dropped, marking the
+	// client behind on "overworld" only.
+	ch <- "filler"
+	ws.fanOut("overworld", false, 0, "data: delta-overworld\n\n")
+	if !client.isBehind("overworld") {
+		t.Fatalf("client.isBehind(\"overworld\") = false after a dropped delta, want true")
+	}
+	<-ch // drain the filler so the channel can accept the next send
+
+	// A keyframe for a different dimension succeeds and must only clear
+	// (and record) that dimension's own state.
+	ws.fanOut("underground", true, 7, "data: keyframe-underground\n\n")
+	if client.isBehind("underground") {
+		t.Fatalf("client.isBehind(\"underground\") = true after its own successful keyframe, want false")
+	}
+	if !client.isBehind("overworld") {
+		t.Fatalf("client.isBehind(\"overworld\") = false after an unrelated dimension's keyframe, want still true")
+	}
+	if _, ok := ws.clients[ch]; !ok {
+		t.Fatalf("client was removed, want it kept (only overworld is behind, and non-keyframe drops don't remove)")
+	}
+}
+
+// TestClientStateLastKeyframeIDIsPerDimension checks that an unfiltered
+// client's lastKeyframeID is tracked independently for each dimension it
+// resyncs, the way handleSSE's per-dimension resync loop sets it, rather
+// than one scalar field a later dimension's resync would overwrite.
+func TestClientStateLastKeyframeIDIsPerDimension(t *testing.T) {
+	client := &ClientState{ch: make(chan string, 1)}
+
+	client.setLastKeyframeID("overworld", 3)
+	client.setLastKeyframeID("underground", 9)
+
+	if client.lastKeyframeID["overworld"] != 3 {
+		t.Fatalf("lastKeyframeID[\"overworld\"] = %d, want 3", client.lastKeyframeID["overworld"])
+	}
+	if client.lastKeyframeID["underground"] != 9 {
+		t.Fatalf("lastKeyframeID[\"underground\"] = %d, want 9", client.lastKeyframeID["underground"])
+	}
+}
+
+// TestBroadcastKeyframeDoesNotDeadlockWithConcurrentTick guards against a
+// lock-order inversion: a tick takes w.mu then state.mu (via Simulate ->
+// broadcastDelta), so broadcastKeyframe/resyncMessages must never hold
+// state.mu while acquiring w.mu (e.g. through WithMap), or the two
+// goroutines can deadlock on each other's lock.
+func TestBroadcastKeyframeDoesNotDeadlockWithConcurrentTick(t *testing.T) {
+	ws := newTestSimulator(t)
+	dw := ws.dimension("overworld")
+	dw.restore(1, &world.Map{Parcels: map[int]*world.Parcel{1: {ID: 1, Elevation: 1}}})
+	dw.StartSimulation()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			dw.Simulate(1, nil, func(ctx *TickContext) {
+				ws.broadcastDelta("overworld", ctx)
+			})
+		}
+	}()
+
+	for i := 0; i < 200; i++ {
+		ws.broadcastKeyframe("overworld", dw)
+		ws.resyncMessages("overworld", dw, 0, false)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for the concurrent tick loop; likely a state.mu/w.mu lock-order deadlock")
+	}
+}
@@ -0,0 +1,62 @@
+// Package world holds the core map domain types shared by the simulator,
+// the persistent store, and anything else that needs to read or write world
+// state without depending on package main.
+package world
+
+// Point represents a 2D coordinate
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Resource represents a resource with dynamic properties
+type Resource struct {
+	Type       string  `json:"type"`
+	Current    float64 `json:"current"`
+	Maximum    float64 `json:"maximum"`
+	ChangeRate float64 `json:"changeRate"`
+}
+
+// Portal links a parcel to a parcel in another dimension, letting handlers
+// move resources or agents between layered worlds (e.g. underground mining
+// affecting surface elevation).
+type Portal struct {
+	Dimension string `json:"dimension"`
+	ParcelID  int    `json:"parcelId"`
+}
+
+// Parcel represents a map region
+type Parcel struct {
+	ID          int        `json:"id"`
+	Vertices    []Point    `json:"vertices"`
+	Center      Point      `json:"center"`
+	Terrain     string     `json:"terrain"`
+	Resources   []Resource `json:"resources"`
+	Neighbors   []int      `json:"neighbors"`
+	Elevation   float64    `json:"elevation"`
+	Moisture    float64    `json:"moisture"`
+	Temperature float64    `json:"temperature"`
+	Portal      *Portal    `json:"portal,omitempty"`
+}
+
+// Entity is a mobile, stateful actor living on top of the parcel grid:
+// population groups, traders, herds, weather fronts, and the like. This
+// package only holds entity data; what drives it on each tick is an
+// EntityBehavior looked up by Type (see package main), keeping this package
+// free of a dependency on the simulation pipeline.
+type Entity struct {
+	ID       int64                  `json:"id"`
+	Type     string                 `json:"type"`
+	Position Point                  `json:"position"`
+	ParcelID int                    `json:"parcelId"`
+	State    map[string]interface{} `json:"state,omitempty"`
+}
+
+// Map represents the complete world state
+type Map struct {
+	Parcels    map[int]*Parcel   `json:"parcels"`
+	Entities   map[int64]*Entity `json:"entities"`
+	Width      float64           `json:"width"`
+	Height     float64           `json:"height"`
+	LastUpdate int64             `json:"lastUpdate"`
+}
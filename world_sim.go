@@ -0,0 +1,328 @@
+package main
+
+import (
+	"log"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/wzshiming/civilization/world"
+)
+
+// DefaultDimension is the dimension used when a request doesn't specify one,
+// keeping single-world callers (and the original API) working unchanged.
+const DefaultDimension = "overworld"
+
+// dimensionNamePattern restricts dimension names to characters that are
+// safe to embed unescaped as a "#"-delimited field in worldstore keys (see
+// worldstore.Store); a name containing "#" could otherwise make one
+// dimension's prefix scan match another, differently-named dimension's keys.
+var dimensionNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// validDimensionName reports whether name is safe to use as a dimension name.
+func validDimensionName(name string) bool {
+	return dimensionNamePattern.MatchString(name)
+}
+
+// World holds one dimension's map and simulation state: its own parcels,
+// tick counter, and handler pipeline, independent of every other dimension.
+type World struct {
+	name string
+
+	mu              sync.RWMutex
+	mapData         *world.Map
+	isSimulating    bool
+	simulationSpeed float64
+	seed            int64
+	numParcels      int
+	tick            uint64
+
+	handlersMu sync.RWMutex
+	handlers   []SimulationHandler
+}
+
+// newWorld creates an empty, unsimulated World with the default handler
+// pipeline registered.
+func newWorld(name string) *World {
+	w := &World{
+		name:            name,
+		simulationSpeed: 1.0,
+		numParcels:      500,
+	}
+
+	w.AddHandler(&ResourceRegrowthHandler{})
+	w.AddHandler(&HydrologyHandler{})
+	w.AddHandler(&TemperatureDiffusionHandler{})
+	w.AddHandler(&PopulationHandler{})
+	w.AddHandler(&PortalHandler{})
+	w.AddHandler(&EntityHandler{})
+
+	return w
+}
+
+// Generate replaces the world's map with a newly generated one.
+func (w *World) Generate(width, height float64, numParcels int, seed int64) *world.Map {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	w.seed = seed
+	w.numParcels = numParcels
+	w.mapData = generateWorld(width, height, numParcels, seed)
+	return w.mapData
+}
+
+// restore installs a world reconstructed from the store (or elsewhere)
+// without going through generation.
+func (w *World) restore(seed int64, m *world.Map) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if m.Entities == nil {
+		m.Entities = make(map[int64]*world.Entity)
+	}
+	w.seed = seed
+	w.numParcels = len(m.Parcels)
+	w.mapData = m
+}
+
+// AddEntity registers a new entity in this dimension's world.
+func (w *World) AddEntity(e *world.Entity) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.mapData == nil {
+		return
+	}
+	if w.mapData.Entities == nil {
+		w.mapData.Entities = make(map[int64]*world.Entity)
+	}
+	w.mapData.Entities[e.ID] = e
+}
+
+// GetEntity looks up an entity by ID in this dimension.
+func (w *World) GetEntity(id int64) (*world.Entity, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.mapData == nil {
+		return nil, false
+	}
+	e, ok := w.mapData.Entities[id]
+	return e, ok
+}
+
+// EntitiesOnParcel returns every entity in this dimension currently on the
+// given parcel.
+func (w *World) EntitiesOnParcel(parcelID int) []*world.Entity {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.mapData == nil {
+		return nil
+	}
+	var entities []*world.Entity
+	for _, e := range w.mapData.Entities {
+		if e.ParcelID == parcelID {
+			entities = append(entities, e)
+		}
+	}
+	return entities
+}
+
+// AddHandler registers a SimulationHandler to run on every tick, after any
+// handlers already registered for this dimension.
+func (w *World) AddHandler(h SimulationHandler) {
+	w.handlersMu.Lock()
+	defer w.handlersMu.Unlock()
+	w.handlers = append(w.handlers, h)
+}
+
+// RemoveHandler unregisters the handler with the given name, if present.
+func (w *World) RemoveHandler(name string) {
+	w.handlersMu.Lock()
+	defer w.handlersMu.Unlock()
+	for i, h := range w.handlers {
+		if h.Name() == name {
+			w.handlers = append(w.handlers[:i], w.handlers[i+1:]...)
+			return
+		}
+	}
+}
+
+// Simulate advances this dimension by one tick, running every registered
+// handler in order, then invokes fn with the resulting TickContext while
+// still holding this dimension's lock, so fn's reads of the tick's world
+// state (e.g. to persist or broadcast it) can't race with concurrent writes
+// from this dimension's API handlers, like AddEntity. It does nothing if
+// the dimension has no world yet or isn't currently simulating, or if fn is
+// nil. mutateOther lets handlers (like PortalHandler) safely read and
+// mutate a single parcel in another dimension, under that dimension's own
+// lock; it may be nil.
+//
+// Between handlers, any registered ParcelChangeHandler is notified of every
+// parcel a handler just changed, so later handlers don't each have to
+// re-scan the whole map looking for what moved. This diffing is skipped
+// entirely when no handler implements ParcelChangeHandler.
+func (w *World) Simulate(deltaTime float64, mutateOther func(dimension string, parcelID int, fn func(p *world.Parcel)) bool, fn func(ctx *TickContext)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.mapData == nil || !w.isSimulating {
+		return
+	}
+
+	w.tick++
+	ctx := &TickContext{
+		World:             w.mapData,
+		Dimension:         w.name,
+		DeltaTime:         deltaTime,
+		Tick:              w.tick,
+		MutateOtherParcel: mutateOther,
+	}
+
+	w.handlersMu.RLock()
+	handlers := make([]SimulationHandler, len(w.handlers))
+	copy(handlers, w.handlers)
+	w.handlersMu.RUnlock()
+
+	var changeListeners []ParcelChangeHandler
+	for _, h := range handlers {
+		if l, ok := h.(ParcelChangeHandler); ok {
+			changeListeners = append(changeListeners, l)
+		}
+	}
+
+	for _, h := range handlers {
+		var before map[int]parcelSnapshot
+		if len(changeListeners) > 0 {
+			before = make(map[int]parcelSnapshot, len(ctx.World.Parcels))
+			for id, p := range ctx.World.Parcels {
+				before[id] = snapshotOf(p)
+			}
+		}
+
+		if err := h.OnTick(ctx); err != nil {
+			log.Printf("dimension %q: handler %q returned error: %v", w.name, h.Name(), err)
+		}
+
+		for id, prev := range before {
+			cur, ok := ctx.World.Parcels[id]
+			if !ok {
+				continue
+			}
+			next := snapshotOf(cur)
+			if prev.equal(next) {
+				continue
+			}
+			old := *cur
+			old.Elevation = prev.Elevation
+			old.Moisture = prev.Moisture
+			old.Temperature = prev.Temperature
+			old.Resources = prev.Resources
+			for _, listener := range changeListeners {
+				listener.OnParcelChange(&old, cur)
+			}
+		}
+	}
+
+	w.mapData.LastUpdate = time.Now().UnixMilli()
+
+	if fn != nil {
+		fn(ctx)
+	}
+}
+
+// WithParcel looks up a parcel by ID in this dimension and, if found, calls
+// fn with it while still holding this dimension's lock, so the read and
+// whatever mutation fn performs happen atomically against this dimension's
+// own tick and API handlers. It reports whether the parcel was found.
+func (w *World) WithParcel(parcelID int, fn func(p *world.Parcel)) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.mapData == nil {
+		return false
+	}
+	p, ok := w.mapData.Parcels[parcelID]
+	if !ok {
+		return false
+	}
+	fn(p)
+	return true
+}
+
+func (w *World) StartSimulation() {
+	w.mu.Lock()
+	w.isSimulating = true
+	w.mu.Unlock()
+	log.Printf("Simulation started for dimension %q", w.name)
+}
+
+func (w *World) StopSimulation() {
+	w.mu.Lock()
+	w.isSimulating = false
+	w.mu.Unlock()
+	log.Printf("Simulation stopped for dimension %q", w.name)
+}
+
+func (w *World) SetSpeed(speed float64) {
+	w.mu.Lock()
+	w.simulationSpeed = speed
+	w.mu.Unlock()
+	log.Printf("Dimension %q speed set to: %.1fx", w.name, speed)
+}
+
+func (w *World) GetMap() *world.Map {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.mapData
+}
+
+// WithMap calls fn with this dimension's current map while holding this
+// dimension's lock for the duration, so fn can safely read (e.g. marshal)
+// the map without racing against a concurrent tick or a cross-dimension
+// portal write via WithParcel. It's a no-op if the dimension has no world
+// yet. Prefer this over GetMap whenever the caller does more than check
+// for nil or read a single pointer.
+func (w *World) WithMap(fn func(m *world.Map)) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.mapData == nil {
+		return
+	}
+	fn(w.mapData)
+}
+
+func (w *World) IsSimulating() bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.isSimulating
+}
+
+func (w *World) GetSpeed() float64 {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.simulationSpeed
+}
+
+// Seed returns the seed this dimension's map was generated (or restored)
+// with.
+func (w *World) Seed() int64 {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.seed
+}
+
+// NumParcels returns the parcel count this dimension's map was generated
+// with.
+func (w *World) NumParcels() int {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.numParcels
+}
+
+// Tick returns the number of ticks this dimension has simulated so far.
+func (w *World) Tick() uint64 {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.tick
+}
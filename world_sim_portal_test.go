@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/wzshiming/civilization/world"
+)
+
+// TestPortalHandlerMutatesLinkedParcelThroughLock exercises PortalHandler
+// end to end across two dimensions' Worlds, going through
+// WorldSimulator.otherDimensionParcel the same way simulateDimension does,
+// so the elevation write happens under the target dimension's own lock
+// rather than through a bare pointer.
+func TestPortalHandlerMutatesLinkedParcelThroughLock(t *testing.T) {
+	ws := newTestSimulator(t)
+
+	a := ws.dimension("overworld")
+	a.restore(1, &world.Map{Parcels: map[int]*world.Parcel{
+		10: {ID: 10, Elevation: 1, Portal: &world.Portal{Dimension: "underground", ParcelID: 20}},
+	}})
+
+	b := ws.dimension("underground")
+	b.restore(1, &world.Map{Parcels: map[int]*world.Parcel{
+		20: {ID: 20, Elevation: 0},
+	}})
+
+	ctx := &TickContext{
+		World:             a.GetMap(),
+		Dimension:         "overworld",
+		DeltaTime:         1,
+		MutateOtherParcel: ws.otherDimensionParcel,
+	}
+
+	if err := (&PortalHandler{}).OnTick(ctx); err != nil {
+		t.Fatalf("OnTick: %v", err)
+	}
+
+	linked := b.GetMap().Parcels[20]
+	if linked.Elevation <= 0 {
+		t.Fatalf("linked.Elevation = %v, want it pulled above 0 toward the portal parcel", linked.Elevation)
+	}
+
+	if len(ctx.Events) != 1 || ctx.Events[0].Type != "portal_transfer" {
+		t.Fatalf("events = %v, want a single portal_transfer event", ctx.Events)
+	}
+}
+
+// TestPortalHandlerSkipsUnknownDimension checks that a portal pointing at a
+// dimension that doesn't exist yet is a no-op rather than a panic.
+func TestPortalHandlerSkipsUnknownDimension(t *testing.T) {
+	ws := newTestSimulator(t)
+
+	a := ws.dimension("overworld")
+	a.restore(1, &world.Map{Parcels: map[int]*world.Parcel{
+		10: {ID: 10, Elevation: 1, Portal: &world.Portal{Dimension: "nowhere", ParcelID: 20}},
+	}})
+
+	ctx := &TickContext{
+		World:             a.GetMap(),
+		Dimension:         "overworld",
+		DeltaTime:         1,
+		MutateOtherParcel: ws.otherDimensionParcel,
+	}
+
+	if err := (&PortalHandler{}).OnTick(ctx); err != nil {
+		t.Fatalf("OnTick: %v", err)
+	}
+	if len(ctx.Events) != 0 {
+		t.Fatalf("events = %v, want none for a portal into a nonexistent dimension", ctx.Events)
+	}
+}
+
+// TestSimulateWithPortalRacesAgainstConcurrentMarshal exercises one
+// dimension repeatedly ticking a portal into a second dimension,
+// concurrently with something reading that second dimension's live map the
+// way the SSE keyframe encoder and handleSSE's initial snapshot do (via
+// WithMap, not a bare GetMap pointer). Run with -race.
+func TestSimulateWithPortalRacesAgainstConcurrentMarshal(t *testing.T) {
+	ws := newTestSimulator(t)
+
+	a := ws.dimension("overworld")
+	a.restore(1, &world.Map{Parcels: map[int]*world.Parcel{
+		10: {ID: 10, Elevation: 1, Portal: &world.Portal{Dimension: "underground", ParcelID: 20}},
+	}})
+	a.StartSimulation()
+
+	b := ws.dimension("underground")
+	b.restore(1, &world.Map{Parcels: map[int]*world.Parcel{
+		20: {ID: 20, Elevation: 0},
+	}})
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			ws.simulateDimension("overworld", a, 1)
+		}
+		close(stop)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			b.WithMap(func(m *world.Map) {
+				if _, err := json.Marshal(m); err != nil {
+					t.Errorf("marshaling underground's map: %v", err)
+				}
+			})
+		}
+	}()
+
+	wg.Wait()
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/wzshiming/civilization/world"
+)
+
+func TestValidDimensionName(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"overworld", true},
+		{"underground_2", true},
+		{"sky-realm", true},
+		{"", false},
+		{"evil#0000000123", false},
+		{"has space", false},
+		{"../etc", false},
+	}
+
+	for _, tt := range tests {
+		if got := validDimensionName(tt.name); got != tt.want {
+			t.Errorf("validDimensionName(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// recordingChangeHandler is a no-op SimulationHandler that also implements
+// ParcelChangeHandler, recording every change it's notified of so tests can
+// assert on what Simulate reported.
+type recordingChangeHandler struct {
+	changes []int
+}
+
+func (h *recordingChangeHandler) Name() string            { return "recording_change" }
+func (h *recordingChangeHandler) OnTick(ctx *TickContext) error { return nil }
+func (h *recordingChangeHandler) OnParcelChange(old, new *world.Parcel) {
+	h.changes = append(h.changes, new.ID)
+}
+
+// mutatingHandler unconditionally sets one parcel's elevation, so tests can
+// drive a known parcel change through Simulate's handler pipeline.
+type mutatingHandler struct {
+	parcelID  int
+	elevation float64
+}
+
+func (h *mutatingHandler) Name() string { return "mutating" }
+func (h *mutatingHandler) OnTick(ctx *TickContext) error {
+	if p, ok := ctx.World.Parcels[h.parcelID]; ok {
+		p.Elevation = h.elevation
+	}
+	return nil
+}
+
+// TestSimulateNotifiesParcelChangeHandlerOfChangedParcels checks that
+// Simulate diffs parcels around each handler and notifies every registered
+// ParcelChangeHandler of the ones that actually changed, leaving unchanged
+// parcels unreported.
+func TestSimulateNotifiesParcelChangeHandlerOfChangedParcels(t *testing.T) {
+	w := &World{name: "test"}
+	w.restore(1, &world.Map{Parcels: map[int]*world.Parcel{
+		1: {ID: 1, Elevation: 0},
+		2: {ID: 2, Elevation: 0},
+	}})
+	w.StartSimulation()
+
+	recorder := &recordingChangeHandler{}
+	w.AddHandler(&mutatingHandler{parcelID: 1, elevation: 5})
+	w.AddHandler(recorder)
+
+	w.Simulate(1, nil, nil)
+
+	if len(recorder.changes) != 1 || recorder.changes[0] != 1 {
+		t.Fatalf("changes = %v, want exactly [1]", recorder.changes)
+	}
+}
@@ -0,0 +1,270 @@
+// Package worldstore persists world.Map state to a LevelDB database so a
+// WorldSimulator can survive process restarts. Parcels are bucketed into
+// fixed-size spatial chunks so a viewport can be loaded without scanning
+// every parcel in the world. A single store can hold many dimensions
+// (worlds), each namespaced by a dimension name.
+package worldstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+
+	"github.com/wzshiming/civilization/world"
+)
+
+// ErrNotFound is returned when a requested key has no value in the store.
+var ErrNotFound = leveldb.ErrNotFound
+
+// ChunkSize is the width and height, in world units, of a spatial bucket.
+// A parcel belongs to the chunk its center falls into.
+const ChunkSize = 64.0
+
+// Store is a LevelDB-backed, write-through persistence layer for world state.
+type Store struct {
+	db *leveldb.DB
+}
+
+// Open opens (creating if necessary) the LevelDB database at path.
+func Open(path string) (*Store, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("opening leveldb at %q: %w", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close releases the underlying database handle.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+type meta struct {
+	Seed   int64   `json:"seed"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// SaveMeta records the generation parameters for a dimension's world.
+func (s *Store) SaveMeta(dimension string, seed int64, width, height float64) error {
+	data, err := json.Marshal(meta{Seed: seed, Width: width, Height: height})
+	if err != nil {
+		return err
+	}
+	return s.db.Put(metaKey(dimension), data, nil)
+}
+
+// LoadMeta returns the generation parameters last saved with SaveMeta for a
+// dimension, or ErrNotFound if none have been saved yet.
+func (s *Store) LoadMeta(dimension string) (seed int64, width, height float64, err error) {
+	data, err := s.db.Get(metaKey(dimension), nil)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	var m meta
+	if err := json.Unmarshal(data, &m); err != nil {
+		return 0, 0, 0, err
+	}
+	return m.Seed, m.Width, m.Height, nil
+}
+
+// ListDimensions returns the name of every dimension that has saved meta,
+// so a resuming simulator knows which worlds to reconstruct.
+func (s *Store) ListDimensions() ([]string, error) {
+	iter := s.db.NewIterator(util.BytesPrefix([]byte(metaPrefix)), nil)
+	defer iter.Release()
+
+	var dimensions []string
+	for iter.Next() {
+		dimensions = append(dimensions, string(iter.Key()[len(metaPrefix):]))
+	}
+	return dimensions, iter.Error()
+}
+
+// SaveParcel writes a parcel's current state, keyed both for direct lookup
+// by ID and for chunked spatial lookup via LoadChunk.
+func (s *Store) SaveParcel(dimension string, id int, p *world.Parcel) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	batch := new(leveldb.Batch)
+	batch.Put(parcelKey(dimension, id), data)
+	cx, cy := chunkOf(p.Center)
+	batch.Put(chunkIndexKey(dimension, cx, cy, id), nil)
+	return s.db.Write(batch, nil)
+}
+
+// LoadParcel looks up a single parcel by ID within a dimension, or returns
+// ErrNotFound.
+func (s *Store) LoadParcel(dimension string, id int) (*world.Parcel, error) {
+	data, err := s.db.Get(parcelKey(dimension, id), nil)
+	if err != nil {
+		return nil, err
+	}
+	var p world.Parcel
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// LoadChunk returns every parcel in a dimension whose center falls in
+// spatial chunk (cx, cy), without scanning parcels outside it.
+func (s *Store) LoadChunk(dimension string, cx, cy int) ([]*world.Parcel, error) {
+	prefix := chunkIndexPrefix(dimension, cx, cy)
+	iter := s.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+
+	var parcels []*world.Parcel
+	for iter.Next() {
+		id, err := parcelIDFromChunkIndexKey(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+		p, err := s.LoadParcel(dimension, id)
+		if err != nil {
+			return nil, err
+		}
+		parcels = append(parcels, p)
+	}
+	return parcels, iter.Error()
+}
+
+// LoadAllParcels loads every persisted parcel for a dimension, for
+// reconstructing its world in full on resume.
+func (s *Store) LoadAllParcels(dimension string) (map[int]*world.Parcel, error) {
+	iter := s.db.NewIterator(util.BytesPrefix([]byte(parcelPrefix+dimension+"#")), nil)
+	defer iter.Release()
+
+	parcels := make(map[int]*world.Parcel)
+	for iter.Next() {
+		var p world.Parcel
+		if err := json.Unmarshal(iter.Value(), &p); err != nil {
+			return nil, err
+		}
+		parcels[p.ID] = &p
+	}
+	return parcels, iter.Error()
+}
+
+// SaveEntity writes an entity's current state for a dimension.
+func (s *Store) SaveEntity(dimension string, id int64, e *world.Entity) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return s.db.Put(entityKey(dimension, id), data, nil)
+}
+
+// DeleteEntity removes a persisted entity from a dimension.
+func (s *Store) DeleteEntity(dimension string, id int64) error {
+	return s.db.Delete(entityKey(dimension, id), nil)
+}
+
+// LoadAllEntities loads every persisted entity for a dimension, for
+// reconstructing its world in full on resume.
+func (s *Store) LoadAllEntities(dimension string) (map[int64]*world.Entity, error) {
+	iter := s.db.NewIterator(util.BytesPrefix([]byte(entityPrefix+dimension+"#")), nil)
+	defer iter.Release()
+
+	entities := make(map[int64]*world.Entity)
+	for iter.Next() {
+		var e world.Entity
+		if err := json.Unmarshal(iter.Value(), &e); err != nil {
+			return nil, err
+		}
+		entities[e.ID] = &e
+	}
+	return entities, iter.Error()
+}
+
+// ParcelDelta is one parcel's state as of a given tick, recorded in the
+// append-only tick journal.
+type ParcelDelta struct {
+	ParcelID    int              `json:"parcelId"`
+	Elevation   float64          `json:"elevation"`
+	Moisture    float64          `json:"moisture"`
+	Temperature float64          `json:"temperature"`
+	Resources   []world.Resource `json:"resources"`
+}
+
+// AppendTick writes the per-parcel deltas for one dimension's simulation
+// tick to the append-only journal. The journal is ordered by tick number
+// within each dimension.
+func (s *Store) AppendTick(dimension string, tick uint64, delta []ParcelDelta) error {
+	data, err := json.Marshal(delta)
+	if err != nil {
+		return err
+	}
+	return s.db.Put(tickKey(dimension, tick), data, nil)
+}
+
+// LoadTick returns the parcel deltas recorded for a given dimension and tick.
+func (s *Store) LoadTick(dimension string, tick uint64) ([]ParcelDelta, error) {
+	data, err := s.db.Get(tickKey(dimension, tick), nil)
+	if err != nil {
+		return nil, err
+	}
+	var delta []ParcelDelta
+	if err := json.Unmarshal(data, &delta); err != nil {
+		return nil, err
+	}
+	return delta, nil
+}
+
+const (
+	metaPrefix   = "meta#"
+	parcelPrefix = "parcel#"
+	chunkPrefix  = "chunk#"
+	tickPrefix   = "tick#"
+	entityPrefix = "entity#"
+)
+
+func metaKey(dimension string) []byte {
+	return []byte(metaPrefix + dimension)
+}
+
+func parcelKey(dimension string, id int) []byte {
+	return []byte(fmt.Sprintf("%s%s#%010d", parcelPrefix, dimension, id))
+}
+
+func chunkIndexPrefix(dimension string, cx, cy int) []byte {
+	return []byte(fmt.Sprintf("%s%s#%010d#%010d#", chunkPrefix, dimension, cx, cy))
+}
+
+func chunkIndexKey(dimension string, cx, cy, id int) []byte {
+	return []byte(fmt.Sprintf("%s%010d#", chunkIndexPrefix(dimension, cx, cy), id))
+}
+
+func parcelIDFromChunkIndexKey(key []byte) (int, error) {
+	// chunkIndexKey is "chunk#<dimension>#<cx>#<cy>#<id>#"; the id is the
+	// second-to-last "#"-separated field (the last field is empty, from the
+	// trailing separator).
+	parts := strings.Split(string(key), "#")
+	if len(parts) < 2 {
+		return 0, fmt.Errorf("malformed chunk index key %q", key)
+	}
+	id, err := strconv.Atoi(parts[len(parts)-2])
+	if err != nil {
+		return 0, fmt.Errorf("malformed chunk index key %q: %w", key, err)
+	}
+	return id, nil
+}
+
+func tickKey(dimension string, tick uint64) []byte {
+	return []byte(fmt.Sprintf("%s%s#%020d", tickPrefix, dimension, tick))
+}
+
+func entityKey(dimension string, id int64) []byte {
+	return []byte(fmt.Sprintf("%s%s#%020d", entityPrefix, dimension, id))
+}
+
+func chunkOf(center world.Point) (int, int) {
+	return int(center.X / ChunkSize), int(center.Y / ChunkSize)
+}
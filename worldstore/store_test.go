@@ -0,0 +1,201 @@
+package worldstore
+
+import (
+	"testing"
+
+	"github.com/wzshiming/civilization/world"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSaveLoadMeta(t *testing.T) {
+	s := openTestStore(t)
+
+	if _, _, _, err := s.LoadMeta("overworld"); err != ErrNotFound {
+		t.Fatalf("LoadMeta before SaveMeta = %v, want ErrNotFound", err)
+	}
+
+	if err := s.SaveMeta("overworld", 42, 100, 200); err != nil {
+		t.Fatalf("SaveMeta: %v", err)
+	}
+	seed, width, height, err := s.LoadMeta("overworld")
+	if err != nil {
+		t.Fatalf("LoadMeta: %v", err)
+	}
+	if seed != 42 || width != 100 || height != 200 {
+		t.Fatalf("LoadMeta = (%d, %v, %v), want (42, 100, 200)", seed, width, height)
+	}
+}
+
+func TestListDimensions(t *testing.T) {
+	s := openTestStore(t)
+
+	if err := s.SaveMeta("overworld", 1, 10, 10); err != nil {
+		t.Fatalf("SaveMeta: %v", err)
+	}
+	if err := s.SaveMeta("underground", 2, 10, 10); err != nil {
+		t.Fatalf("SaveMeta: %v", err)
+	}
+
+	dims, err := s.ListDimensions()
+	if err != nil {
+		t.Fatalf("ListDimensions: %v", err)
+	}
+
+	seen := map[string]bool{}
+	for _, d := range dims {
+		seen[d] = true
+	}
+	if !seen["overworld"] || !seen["underground"] || len(dims) != 2 {
+		t.Fatalf("ListDimensions = %v, want exactly [overworld underground]", dims)
+	}
+}
+
+func TestSaveLoadParcel(t *testing.T) {
+	s := openTestStore(t)
+
+	p := &world.Parcel{ID: 5, Elevation: 0.5, Center: world.Point{X: 10, Y: 20}}
+	if err := s.SaveParcel("overworld", 5, p); err != nil {
+		t.Fatalf("SaveParcel: %v", err)
+	}
+
+	got, err := s.LoadParcel("overworld", 5)
+	if err != nil {
+		t.Fatalf("LoadParcel: %v", err)
+	}
+	if got.ID != 5 || got.Elevation != 0.5 {
+		t.Fatalf("LoadParcel = %+v, want ID 5, Elevation 0.5", got)
+	}
+
+	if _, err := s.LoadParcel("overworld", 999); err != ErrNotFound {
+		t.Fatalf("LoadParcel(999) = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSaveParcelNamespacesByDimension(t *testing.T) {
+	s := openTestStore(t)
+
+	a := &world.Parcel{ID: 1, Elevation: 1}
+	b := &world.Parcel{ID: 1, Elevation: 2}
+	if err := s.SaveParcel("overworld", 1, a); err != nil {
+		t.Fatalf("SaveParcel(overworld): %v", err)
+	}
+	if err := s.SaveParcel("underground", 1, b); err != nil {
+		t.Fatalf("SaveParcel(underground): %v", err)
+	}
+
+	got, err := s.LoadParcel("overworld", 1)
+	if err != nil {
+		t.Fatalf("LoadParcel(overworld, 1): %v", err)
+	}
+	if got.Elevation != 1 {
+		t.Fatalf("overworld parcel 1 Elevation = %v, want 1 (not clobbered by underground)", got.Elevation)
+	}
+}
+
+func TestLoadAllParcels(t *testing.T) {
+	s := openTestStore(t)
+
+	for id := 1; id <= 3; id++ {
+		if err := s.SaveParcel("overworld", id, &world.Parcel{ID: id}); err != nil {
+			t.Fatalf("SaveParcel(%d): %v", id, err)
+		}
+	}
+	// A different dimension's parcels must not leak into overworld's load.
+	if err := s.SaveParcel("underground", 1, &world.Parcel{ID: 1}); err != nil {
+		t.Fatalf("SaveParcel(underground, 1): %v", err)
+	}
+
+	parcels, err := s.LoadAllParcels("overworld")
+	if err != nil {
+		t.Fatalf("LoadAllParcels: %v", err)
+	}
+	if len(parcels) != 3 {
+		t.Fatalf("LoadAllParcels returned %d parcels, want 3", len(parcels))
+	}
+}
+
+func TestLoadChunkBucketsBySpatialChunk(t *testing.T) {
+	s := openTestStore(t)
+
+	near := &world.Parcel{ID: 1, Center: world.Point{X: 10, Y: 10}}
+	alsoNear := &world.Parcel{ID: 2, Center: world.Point{X: 20, Y: 5}}
+	far := &world.Parcel{ID: 3, Center: world.Point{X: 200, Y: 200}}
+
+	for _, p := range []*world.Parcel{near, alsoNear, far} {
+		if err := s.SaveParcel("overworld", p.ID, p); err != nil {
+			t.Fatalf("SaveParcel(%d): %v", p.ID, err)
+		}
+	}
+
+	cx, cy := chunkOf(world.Point{X: 10, Y: 10})
+	got, err := s.LoadChunk("overworld", cx, cy)
+	if err != nil {
+		t.Fatalf("LoadChunk: %v", err)
+	}
+
+	ids := map[int]bool{}
+	for _, p := range got {
+		ids[p.ID] = true
+	}
+	if len(got) != 2 || !ids[1] || !ids[2] {
+		t.Fatalf("LoadChunk(%d, %d) = %v, want parcels 1 and 2 only", cx, cy, got)
+	}
+}
+
+func TestSaveLoadDeleteEntity(t *testing.T) {
+	s := openTestStore(t)
+
+	e := &world.Entity{ID: 7, Type: "herd", ParcelID: 3}
+	if err := s.SaveEntity("overworld", 7, e); err != nil {
+		t.Fatalf("SaveEntity: %v", err)
+	}
+
+	entities, err := s.LoadAllEntities("overworld")
+	if err != nil {
+		t.Fatalf("LoadAllEntities: %v", err)
+	}
+	if len(entities) != 1 || entities[7].Type != "herd" {
+		t.Fatalf("LoadAllEntities = %+v, want exactly entity 7 (herd)", entities)
+	}
+
+	if err := s.DeleteEntity("overworld", 7); err != nil {
+		t.Fatalf("DeleteEntity: %v", err)
+	}
+	entities, err = s.LoadAllEntities("overworld")
+	if err != nil {
+		t.Fatalf("LoadAllEntities after delete: %v", err)
+	}
+	if len(entities) != 0 {
+		t.Fatalf("LoadAllEntities after delete = %+v, want none", entities)
+	}
+}
+
+func TestAppendLoadTick(t *testing.T) {
+	s := openTestStore(t)
+
+	delta := []ParcelDelta{{ParcelID: 1, Elevation: 0.75}}
+	if err := s.AppendTick("overworld", 10, delta); err != nil {
+		t.Fatalf("AppendTick: %v", err)
+	}
+
+	got, err := s.LoadTick("overworld", 10)
+	if err != nil {
+		t.Fatalf("LoadTick: %v", err)
+	}
+	if len(got) != 1 || got[0].ParcelID != 1 || got[0].Elevation != 0.75 {
+		t.Fatalf("LoadTick = %+v, want [{ParcelID: 1, Elevation: 0.75}]", got)
+	}
+
+	if _, err := s.LoadTick("overworld", 11); err != ErrNotFound {
+		t.Fatalf("LoadTick(11) = %v, want ErrNotFound for a tick never appended", err)
+	}
+}